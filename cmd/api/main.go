@@ -4,12 +4,17 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/truncgil/gorecta/internal/api/routes"
 	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/auth"
 	"github.com/truncgil/gorecta/pkg/database"
+	"github.com/truncgil/gorecta/pkg/mailer"
+	"github.com/truncgil/gorecta/pkg/oidc"
 )
 
 // @title GoRecta CMS API
@@ -71,10 +76,105 @@ func main() {
 		&models.Post{},
 		&models.Category{},
 		&models.Tag{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.PasswordResetToken{},
+		&models.EmailVerificationToken{},
+		&models.RecoveryCode{},
+		&models.Setting{},
+		&models.AuditEvent{},
+		&models.LoginThrottle{},
+		&models.APIKey{},
+		&models.Role{},
+		&models.Permission{},
+		&models.UserIdentity{},
+		&models.OIDCLoginState{},
+		&models.Invitation{},
+		&models.Session{},
+		&models.PostRevision{},
+		&models.PostTransition{},
+		&models.SlugHistory{},
 	); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	// Give posts published before statuses existed the published status
+	if err := service.MigratePostStatuses(db); err != nil {
+		log.Fatalf("Failed to migrate post statuses: %v", err)
+	}
+
+	// Build the full-text search index of posts
+	if err := service.EnsureSearchIndex(db); err != nil {
+		log.Fatalf("Failed to build search index: %v", err)
+	}
+
+	// Seed built-in roles and permissions
+	if err := service.SeedRoles(db); err != nil {
+		log.Fatalf("Failed to seed roles: %v", err)
+	}
+
+	// Load JWT signing keys
+	if err := auth.LoadKeys(); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	// Initialize mailer
+	if _, err := mailer.InitMailer(); err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// Load OpenID Connect providers
+	if err := oidc.LoadProviders(); err != nil {
+		log.Fatalf("Failed to load OIDC providers: %v", err)
+	}
+
+	// Periodically purge revocation records of expired tokens and stale
+	// failed login attempts
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := service.PurgeExpiredRevocations(db); err != nil {
+				log.Printf("Failed to purge expired token revocations: %v", err)
+			}
+			if err := service.PurgeLoginThrottles(db); err != nil {
+				log.Printf("Failed to purge login throttles: %v", err)
+			}
+		}
+	}()
+
+	// Publish and unpublish scheduled posts when they are due
+	go func() {
+		ticker := time.NewTicker(service.PostSchedulerInterval())
+		defer ticker.Stop()
+		for range ticker.C {
+			published, archived, err := service.RunPostSchedule(db)
+			if err != nil {
+				log.Printf("Failed to run post schedule: %v", err)
+				continue
+			}
+			if published > 0 || archived > 0 {
+				log.Printf("Post schedule: published %d, archived %d", published, archived)
+			}
+		}
+	}()
+
+	// Permanently delete content that has been in the trash too long
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			posts, categories, tags, err := service.PurgeTrash(db)
+			if err != nil {
+				log.Printf("Failed to purge trash: %v", err)
+				continue
+			}
+			if posts > 0 || categories > 0 || tags > 0 {
+				log.Printf("Trash purge: %d posts, %d categories, %d tags", posts, categories, tags)
+			}
+		}
+	}()
+
 	// Initialize router
 	router := gin.Default()
 
@@ -1,18 +1,27 @@
 package handlers
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/service"
 	"github.com/truncgil/gorecta/pkg/auth"
 	"github.com/truncgil/gorecta/pkg/database"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type RegisterRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
 }
 
 type LoginRequest struct {
@@ -20,6 +29,15 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"`
+}
+
 // @Summary Register a new user
 // @Description Register a new user with the provided details
 // @Tags auth
@@ -38,6 +56,10 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	if !checkPasswordPolicy(c, req.Password, req.Email, req.Name) {
+		return
+	}
+
 	// Check if user already exists
 	var existingUser models.User
 	if err := database.GetDB().Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
@@ -58,26 +80,17 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Generate token
-	token, err := auth.GenerateToken(user.ID, user.Email, user.Role)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
+	// Send verification email
+	if err := sendEmailVerification(database.GetDB(), &user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
-			"role":  user.Role,
-		},
-	})
+	respondWithTokens(c, http.StatusCreated, &user)
 }
 
 // @Summary Login user
-// @Description Login with email and password
+// @Description Login with email and password. Users with two-factor authentication enabled
+// @Description receive a challenge_token to complete the login at /auth/2fa/login.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -85,6 +98,8 @@ func Register(c *gin.Context) {
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /auth/login [post]
 func Login(c *gin.Context) {
@@ -94,33 +109,361 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Find user by email
+	// Refuse throttled attempts before spending any time on bcrypt
+	if !loginAllowed(c, req.Email) {
+		return
+	}
+
+	// Find user by email and check password. Unknown emails are compared
+	// against a dummy hash so both cases take the same time.
 	var user models.User
 	if err := database.GetDB().Where("email = ?", req.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		compareDummyPassword(req.Password)
+		loginFailed(c, req.Email)
 		return
 	}
 
-	// Check password
 	if err := user.ComparePassword(req.Password); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		loginFailed(c, req.Email)
+		return
+	}
+
+	if err := service.RecordLoginSuccess(req.Email); err != nil {
+		log.Printf("Failed to reset login attempts: %v", err)
+	}
+
+	// Upgrade the hash while the plaintext password is at hand
+	if user.NeedsRehash() {
+		if err := user.UpdatePassword(req.Password); err == nil {
+			if err := database.GetDB().Model(&user).Update("password", user.Password).Error; err != nil {
+				log.Printf("Failed to rehash password of user %d: %v", user.ID, err)
+			}
+		}
+	}
+
+	completeLogin(c, &user)
+}
+
+// completeLogin finishes a login once the user's credentials have been
+// verified: suspended accounts are rejected, users with two-factor
+// authentication get a challenge and everyone else gets tokens
+func completeLogin(c *gin.Context, user *models.User) {
+	// Reject inactive accounts and lift suspensions that have expired
+	if !user.IsActive() {
+		c.JSON(http.StatusForbidden, suspendedResponse(user))
+		return
+	}
+	if !user.Active {
+		user.Reactivate()
+		if err := database.GetDB().Save(user).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate user"})
+			return
+		}
+	}
+
+	// Users with two-factor authentication get a challenge instead of tokens
+	if user.TwoFactorEnabled {
+		challenge, err := auth.GenerateChallengeToken(user.ID, user.Email, user.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+		return
+	}
+
+	respondWithTokens(c, http.StatusOK, user)
+}
+
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token. The refresh token is
+// @Description rotated on every use; presenting an already-rotated token revokes the whole token family.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest true "Refresh token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/refresh [post]
+func Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var stored models.RefreshToken
+	if err := db.Where("token_hash = ?", auth.HashToken(req.RefreshToken)).First(&stored).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	// A revoked token being presented again means it was stolen or replayed
+	if stored.RevokedAt != nil {
+		revokeTokenFamily(db, stored.FamilyID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected"})
 		return
 	}
 
-	// Generate token
-	token, err := auth.GenerateToken(user.ID, user.Email, user.Role)
+	if time.Now().After(stored.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token expired"})
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, stored.UserID).Error; err != nil || stored.CreatedAt.Before(user.TokensValidAfter) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	if !user.IsActive() {
+		c.JSON(http.StatusForbidden, suspendedResponse(&user))
+		return
+	}
+
+	var tokens gin.H
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Mark the presented token as used; losing this race counts as reuse
+		now := time.Now()
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", stored.ID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefreshTokenReused
+		}
+
+		session, err := service.ResumeSession(tx, user.ID, stored.FamilyID, c.ClientIP(), c.Request.UserAgent())
+		if err != nil {
+			return err
+		}
+
+		var replacementID uint
+		tokens, replacementID, err = issueTokenPair(tx, &user, session)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("id = ?", stored.ID).
+			Update("replaced_by_id", replacementID).Error
+	})
+	if errors.Is(err, errRefreshTokenReused) {
+		revokeTokenFamily(db, stored.FamilyID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected"})
+		return
+	}
+	if errors.Is(err, service.ErrTokenRevoked) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// @Summary Logout
+// @Description Revoke the current access token and its session and, if provided, the refresh
+// @Description token family it belongs to. With "all" set, every token issued to the user is invalidated.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body LogoutRequest false "Logout options"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/logout [post]
+func Logout(c *gin.Context) {
+	var req LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	claims := c.MustGet("claims").(*auth.Claims)
+	db := database.GetDB()
+
+	// Signing the user out everywhere is up to the user, not an impersonator
+	if req.All && claims.IsImpersonation() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed while impersonating"})
+		return
+	}
+
+	if err := service.RevokeToken(db, claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		return
+	}
+
+	if claims.SessionID != 0 {
+		if err := service.RevokeSession(db, claims.UserID, claims.SessionID); err != nil && !errors.Is(err, service.ErrSessionNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+			return
+		}
+	}
+
+	if req.RefreshToken != "" {
+		var stored models.RefreshToken
+		if err := db.Where("token_hash = ? AND user_id = ?", auth.HashToken(req.RefreshToken), claims.UserID).First(&stored).Error; err == nil {
+			revokeTokenFamily(db, stored.FamilyID)
+		}
+	}
+
+	if req.All {
+		if err := service.InvalidateUserTokens(db, claims.UserID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke tokens"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+// respondWithTokens issues a token pair for the user and writes it together
+// with a summary of the user
+func respondWithTokens(c *gin.Context, status int, user *models.User) {
+	db := database.GetDB()
+
+	session, err := service.StartSession(db, user.ID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	tokens, _, err := issueTokenPair(db, user, session)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
-			"role":  user.Role,
-		},
+	tokens["user"] = gin.H{
+		"id":                 user.ID,
+		"name":               user.Name,
+		"email":              user.Email,
+		"role":               user.Role,
+		"email_verified":     user.EmailVerified,
+		"two_factor_enabled": user.TwoFactorEnabled,
+	}
+	c.JSON(status, tokens)
+}
+
+// issueTokenPair generates an access token and stores a new refresh token for
+// the user's session. The ID of the stored refresh token is returned
+// alongside the response body.
+func issueTokenPair(db *gorm.DB, user *models.User, session *models.Session) (gin.H, uint, error) {
+	accessToken, err := auth.GenerateToken(user.ID, user.Email, user.Role, session.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	refreshToken, refreshHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stored := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: refreshHash,
+		FamilyID:  session.FamilyID,
+		ExpiresAt: time.Now().Add(auth.RefreshTokenTTL()),
+	}
+	if err := db.Create(&stored).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// The session lives as long as its newest refresh token
+	if err := db.Model(session).Update("expires_at", stored.ExpiresAt).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return gin.H{
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(auth.AccessTokenTTL().Seconds()),
+	}, stored.ID, nil
+}
+
+// revokeTokenFamily revokes every outstanding refresh token in a family and
+// the session they belong to
+func revokeTokenFamily(db *gorm.DB, familyID string) {
+	now := time.Now()
+	if err := db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error; err != nil {
+		log.Printf("Failed to revoke refresh token family %s: %v", familyID, err)
+	}
+	if err := db.Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error; err != nil {
+		log.Printf("Failed to revoke session of refresh token family %s: %v", familyID, err)
+	}
+}
+
+var (
+	dummyPasswordHash     []byte
+	dummyPasswordHashOnce sync.Once
+)
+
+// compareDummyPassword spends the same time as a real password check
+func compareDummyPassword(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), auth.PasswordHashCost())
 	})
+	_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+}
+
+// loginAllowed writes a 429 response and returns false if login attempts for
+// the email or from the client IP are currently throttled
+func loginAllowed(c *gin.Context, email string) bool {
+	wait, err := service.LoginRetryAfter(email, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+		return false
+	}
+
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, try again later"})
+		return false
+	}
+
+	return true
+}
+
+// loginFailed records a failed login attempt and writes a 401 response
+func loginFailed(c *gin.Context, email string) {
+	if err := service.RecordLoginFailure(email, c.ClientIP()); err != nil {
+		log.Printf("Failed to record failed login: %v", err)
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+}
+
+// suspendedResponse describes why an inactive user cannot sign in
+func suspendedResponse(user *models.User) gin.H {
+	resp := gin.H{"error": "Account is suspended"}
+	if user.SuspendedReason != "" {
+		resp["reason"] = user.SuspendedReason
+	}
+	if user.SuspendedUntil != nil {
+		resp["suspended_until"] = user.SuspendedUntil
+	}
+	return resp
 }
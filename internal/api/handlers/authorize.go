@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/policy"
+	"github.com/truncgil/gorecta/internal/service"
+)
+
+// hasPermission reports whether the authenticated user's role grants the permission
+func hasPermission(c *gin.Context, permission string) (bool, error) {
+	return service.RoleHasPermission(c.GetString("role"), permission)
+}
+
+// authorize evaluates the resource policy for the authenticated user and
+// writes a 403 response if the action is not allowed
+func authorize(c *gin.Context, action policy.Action, resource policy.Resource) bool {
+	err := policy.Authorize(currentSubject(c), action, resource)
+	if errors.Is(err, policy.ErrForbidden) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return false
+	}
+	return true
+}
+
+// currentSubject returns the policy subject for the authenticated user
+func currentSubject(c *gin.Context) policy.Subject {
+	return policy.Subject{UserID: currentUserID(c), Role: c.GetString("role")}
+}
@@ -6,17 +6,23 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/policy"
+	"github.com/truncgil/gorecta/internal/service"
 	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
 )
 
 type CreateCategoryRequest struct {
 	Name        string `json:"name" binding:"required"`
-	Slug        string `json:"slug" binding:"required"`
 	Description string `json:"description"`
+	// Slug is generated from the name when empty; updates keep the current
+	// slug then
+	Slug string `json:"slug"`
 }
 
 // @Summary Create a new category
-// @Description Create a new category with the provided details
+// @Description Create a new category with the provided details. Without a slug, one is generated
+// @Description from the name, with a numeric suffix if it is taken.
 // @Tags categories
 // @Accept json
 // @Produce json
@@ -25,6 +31,7 @@ type CreateCategoryRequest struct {
 // @Success 200 {object} models.Category
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /categories [post]
 func CreateCategory(c *gin.Context) {
@@ -34,14 +41,22 @@ func CreateCategory(c *gin.Context) {
 		return
 	}
 
+	db := database.GetDB()
+	slug, ok := chooseSlug(c, db, "categories", req.Slug, req.Name, 0)
+	if !ok {
+		return
+	}
+
 	category := models.Category{
 		Name:        req.Name,
-		Slug:        req.Slug,
+		Slug:        slug,
 		Description: req.Description,
 	}
 
-	if err := database.GetDB().Create(&category).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+	if err := db.Create(&category).Error; err != nil {
+		if !slugConflict(c, db, "categories", category.Slug, 0) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+		}
 		return
 	}
 
@@ -49,24 +64,40 @@ func CreateCategory(c *gin.Context) {
 }
 
 // @Summary Get all categories
-// @Description Get a list of all categories
+// @Description Get a page of categories. The total count is returned in the X-Total-Count header
+// @Description and links to other pages in the Link header.
 // @Tags categories
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page (max 100)" default(20)
+// @Param cursor query string false "Cursor from a previous Link header; empty to start cursor paging"
+// @Param sort query string false "name, created or updated; prefix with - for descending" default(name)
 // @Success 200 {array} models.Category
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /categories [get]
 func GetCategories(c *gin.Context) {
 	var categories []models.Category
-	if err := database.GetDB().Find(&categories).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+	if !paginate(c, database.GetDB().Model(&models.Category{}), categoryListOptions, &categories) {
 		return
 	}
 
 	c.JSON(http.StatusOK, categories)
 }
 
+// categoryListOptions are the sort fields of category lists
+var categoryListOptions = listOptions{
+	table: "categories",
+	sorts: map[string]sortField{
+		"created": contentSorts["created"],
+		"updated": contentSorts["updated"],
+		"name":    {column: "name"},
+	},
+	defaultSort: "name",
+}
+
 // @Summary Get a category by ID
 // @Description Get a specific category by its ID
 // @Tags categories
@@ -91,7 +122,8 @@ func GetCategory(c *gin.Context) {
 }
 
 // @Summary Update a category
-// @Description Update an existing category
+// @Description Update an existing category. A changed slug is kept in the category's slug
+// @Description history so that the former slug redirects to the new one.
 // @Tags categories
 // @Accept json
 // @Produce json
@@ -101,7 +133,9 @@ func GetCategory(c *gin.Context) {
 // @Success 200 {object} models.Category
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /categories/{id} [put]
 func UpdateCategory(c *gin.Context) {
@@ -117,18 +151,40 @@ func UpdateCategory(c *gin.Context) {
 		return
 	}
 
+	db := database.GetDB()
+
 	var category models.Category
-	if err := database.GetDB().First(&category, id).Error; err != nil {
+	if err := db.First(&category, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
 		return
 	}
 
+	if !authorize(c, policy.ActionUpdate, &category) {
+		return
+	}
+
+	oldSlug := category.Slug
+	if req.Slug != "" {
+		slug, ok := chooseSlug(c, db, "categories", req.Slug, req.Name, category.ID)
+		if !ok {
+			return
+		}
+		category.Slug = slug
+	}
+
 	category.Name = req.Name
-	category.Slug = req.Slug
 	category.Description = req.Description
 
-	if err := database.GetDB().Save(&category).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&category).Error; err != nil {
+			return err
+		}
+		return service.RecordSlugChange(tx, "categories", category.ID, oldSlug, category.Slug)
+	})
+	if err != nil {
+		if !slugConflict(c, db, "categories", category.Slug, category.ID) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+		}
 		return
 	}
 
@@ -136,7 +192,7 @@ func UpdateCategory(c *gin.Context) {
 }
 
 // @Summary Delete a category
-// @Description Delete a category by its ID
+// @Description Move a category to the trash. Categories that still have posts cannot be deleted.
 // @Tags categories
 // @Accept json
 // @Produce json
@@ -144,7 +200,9 @@ func UpdateCategory(c *gin.Context) {
 // @Param id path int true "Category ID"
 // @Success 200 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]string
 // @Router /categories/{id} [delete]
 func DeleteCategory(c *gin.Context) {
@@ -156,6 +214,23 @@ func DeleteCategory(c *gin.Context) {
 		return
 	}
 
+	if !authorize(c, policy.ActionDelete, &category) {
+		return
+	}
+
+	var postCount int64
+	if err := database.GetDB().Model(&models.Post{}).Where("category_id = ?", category.ID).Count(&postCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count category posts"})
+		return
+	}
+	if postCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      "Category has posts; move them to another category or delete them first",
+			"post_count": postCount,
+		})
+		return
+	}
+
 	if err := database.GetDB().Delete(&category).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
 		return
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/auth"
+	"github.com/truncgil/gorecta/pkg/database"
+)
+
+type ImpersonateUserRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// @Summary Impersonate a user
+// @Description Issue a short-lived token for acting as another user. The token cannot be
+// @Description refreshed, cannot change credentials or two-factor settings, and every request
+// @Description made with it is recorded in the audit log.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body ImpersonateUserRequest true "Reason for impersonating"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/impersonate [post]
+func ImpersonateUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req ImpersonateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorID := currentUserID(c)
+	if uint(id) == actorID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot impersonate yourself"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.IsActive() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot impersonate a suspended user"})
+		return
+	}
+
+	// Admins who can impersonate cannot hide their actions behind each other
+	privileged, err := service.RoleHasPermission(user.Role, service.ImpersonatePermission)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	if privileged {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot impersonate a user who can impersonate others"})
+		return
+	}
+
+	token, err := auth.GenerateImpersonationToken(user.ID, user.Email, user.Role, actorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	service.RecordAudit(db, "impersonation.started", &actorID, &user.ID, c.ClientIP(), map[string]interface{}{
+		"reason": req.Reason,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_in": int(auth.ImpersonationTokenTTL().Seconds()),
+		"user": gin.H{
+			"id":    user.ID,
+			"name":  user.Name,
+			"email": user.Email,
+			"role":  user.Role,
+		},
+	})
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/auth"
+	"github.com/truncgil/gorecta/pkg/database"
+	"github.com/truncgil/gorecta/pkg/mailer"
+	"gorm.io/gorm"
+)
+
+var (
+	errInvitationInvalid = errors.New("invitation is invalid or expired")
+	errEmailRegistered   = errors.New("email already registered")
+)
+
+type CreateInvitationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// @Summary Invite a user
+// @Description Email an invitation to create an account with the given role. Inviting an email
+// @Description again replaces its pending invitation.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateInvitationRequest true "Invitee email and role"
+// @Success 201 {object} models.Invitation
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /invitations [post]
+func CreateInvitation(c *gin.Context) {
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exists, err := service.RoleExists(req.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check role"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role"})
+		return
+	}
+
+	db := database.GetDB()
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	var existingUser models.User
+	if err := db.Where("LOWER(email) = ?", email).First(&existingUser).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		return
+	}
+
+	token, tokenHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation"})
+		return
+	}
+
+	now := time.Now()
+	inviterID := currentUserID(c)
+	invitation := models.Invitation{
+		Email:       email,
+		Role:        req.Role,
+		TokenHash:   tokenHash,
+		InvitedByID: &inviterID,
+		ExpiresAt:   now.Add(invitationTTL()),
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Only the most recent invitation for an email stays valid
+		if err := tx.Model(&models.Invitation{}).
+			Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL", email).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&invitation).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	service.RecordAudit(db, "user.invited", &inviterID, nil, c.ClientIP(), map[string]interface{}{
+		"email": invitation.Email,
+		"role":  invitation.Role,
+	})
+
+	go sendInvitation(invitation, token)
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// @Summary List pending invitations
+// @Description List invitations that have not been accepted, revoked or expired
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Invitation
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /invitations [get]
+func GetInvitations(c *gin.Context) {
+	var invitations []models.Invitation
+	if err := database.GetDB().Preload("InvitedBy").
+		Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// @Summary Revoke an invitation
+// @Description Revoke a pending invitation so that it can no longer be accepted
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /invitations/{id} [delete]
+func RevokeInvitation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	db := database.GetDB()
+	result := db.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	actorID := currentUserID(c)
+	service.RecordAudit(db, "user.invitation_revoked", &actorID, nil, c.ClientIP(), map[string]interface{}{
+		"invitation_id": id,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// @Summary Accept an invitation
+// @Description Create the invited account by choosing a name and password. The email is
+// @Description considered verified since the invitation was delivered to it.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body AcceptInvitationRequest true "Invitation token and account details"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/accept-invitation [post]
+func AcceptInvitation(c *gin.Context) {
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var invitation models.Invitation
+	if err := db.Where("token_hash = ?", auth.HashToken(req.Token)).First(&invitation).Error; err != nil ||
+		!invitation.IsPending() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invitation"})
+		return
+	}
+
+	if !checkPasswordPolicy(c, req.Password, invitation.Email, req.Name) {
+		return
+	}
+
+	now := time.Now()
+	user := models.User{
+		Name:            req.Name,
+		Email:           invitation.Email,
+		Password:        req.Password,
+		Role:            invitation.Role,
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existingUser models.User
+		if err := tx.Where("LOWER(email) = ?", invitation.Email).First(&existingUser).Error; err == nil {
+			return errEmailRegistered
+		}
+
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		// Consume the invitation; losing this race means it was already used
+		result := tx.Model(&models.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).
+			Updates(map[string]interface{}{"accepted_at": now, "accepted_user_id": user.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvitationInvalid
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errInvitationInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invitation"})
+		return
+	case errors.Is(err, errEmailRegistered):
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	service.RecordAudit(db, "user.invitation_accepted", &user.ID, &user.ID, c.ClientIP(), map[string]interface{}{
+		"invitation_id": invitation.ID,
+		"role":          user.Role,
+	})
+
+	respondWithTokens(c, http.StatusCreated, &user)
+}
+
+// sendInvitation mails the invitation link to the invitee
+func sendInvitation(invitation models.Invitation, token string) {
+	link := fmt.Sprintf("%s/accept-invitation?token=%s", os.Getenv("APP_URL"), url.QueryEscape(token))
+	msg := mailer.Message{
+		To:      invitation.Email,
+		Subject: "You have been invited to GoRecta",
+		Body: fmt.Sprintf("Hello,\n\nYou have been invited to join GoRecta as %s. Use the link below to set up "+
+			"your account. It expires in %s.\n\n%s\n\nIf you were not expecting this invitation, you can ignore this email.",
+			invitation.Role, invitationTTL(), link),
+	}
+	if err := mailer.GetMailer().Send(msg); err != nil {
+		log.Printf("Failed to send invitation email: %v", err)
+	}
+}
+
+// invitationTTL returns how long invitation links stay valid
+func invitationTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("INVITATION_EXPIRATION"))
+	if err != nil || ttl <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return ttl
+}
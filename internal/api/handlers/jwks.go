@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/pkg/auth"
+)
+
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying access tokens signed with RS256 or EdDSA
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /.well-known/jwks.json [get]
+func JWKS(c *gin.Context) {
+	jwks, err := auth.JWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load signing keys"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwks)
+}
@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/auth"
+	"github.com/truncgil/gorecta/pkg/database"
+	"github.com/truncgil/gorecta/pkg/oidc"
+	"gorm.io/gorm"
+)
+
+// oidcStateTTL is how long a user has to complete a login at the provider
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	errOIDCLoginState      = errors.New("invalid or expired login state")
+	errOIDCEmailMissing    = errors.New("identity provider did not return an email address")
+	errOIDCEmailUnverified = errors.New("email address is not verified by the identity provider")
+	errOIDCSignupDisabled  = errors.New("no account exists for this identity")
+)
+
+type OIDCCallbackRequest struct {
+	Code             string `form:"code" json:"code"`
+	State            string `form:"state" json:"state" binding:"required"`
+	Error            string `form:"error" json:"error"`
+	ErrorDescription string `form:"error_description" json:"error_description"`
+}
+
+// @Summary List identity providers
+// @Description List the OpenID Connect providers users can log in with
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/oidc/providers [get]
+func GetOIDCProviders(c *gin.Context) {
+	providers := []gin.H{}
+	for _, p := range oidc.Providers() {
+		providers = append(providers, gin.H{"name": p.Name})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"providers": providers})
+}
+
+// @Summary Start an identity provider login
+// @Description Redirect to the provider's authorization endpoint using the authorization code flow
+// @Description with PKCE. With redirect=false the authorization URL is returned instead.
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param redirect query bool false "Set to false to receive the URL as JSON"
+// @Success 200 {object} map[string]string
+// @Success 302
+// @Failure 404 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Router /auth/oidc/{provider}/login [get]
+func OIDCLogin(c *gin.Context) {
+	provider, err := oidc.GetProvider(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+		return
+	}
+
+	state, stateHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	nonce, err := auth.NewTokenID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	verifier, err := oidc.GenerateCodeVerifier()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		log.Printf("OIDC provider %s is unavailable: %v", provider.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+
+	db := database.GetDB()
+	now := time.Now()
+
+	// Abandoned logins are cleaned up as new ones start
+	if err := db.Where("expires_at < ?", now).Delete(&models.OIDCLoginState{}).Error; err != nil {
+		log.Printf("Failed to purge expired OIDC login states: %v", err)
+	}
+
+	loginState := models.OIDCLoginState{
+		StateHash:    stateHash,
+		Provider:     provider.Name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(oidcStateTTL),
+	}
+	if err := db.Create(&loginState).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	if c.Query("redirect") == "false" {
+		c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary Complete an identity provider login
+// @Description Exchange the authorization code returned by the provider for tokens. Users are linked
+// @Description by verified email or created on their first login, with their role mapped from claims.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param request body OIDCCallbackRequest true "Authorization response"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/oidc/{provider}/callback [post]
+func OIDCCallback(c *gin.Context) {
+	provider, err := oidc.GetProvider(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+		return
+	}
+
+	var req OIDCCallbackRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	// The state is single-use even when the login fails
+	loginState, err := consumeOIDCLoginState(db, provider.Name, req.State)
+	if err != nil {
+		if errors.Is(err, errOIDCLoginState) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete login"})
+		}
+		return
+	}
+
+	if req.Error != "" || req.Code == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":             "Login was not completed at the identity provider",
+			"provider_error":    req.Error,
+			"error_description": req.ErrorDescription,
+		})
+		return
+	}
+
+	identity, err := provider.Exchange(c.Request.Context(), req.Code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		log.Printf("OIDC login with %s failed: %v", provider.Name, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to authenticate with identity provider"})
+		return
+	}
+
+	var user models.User
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return resolveOIDCUser(tx, c.ClientIP(), provider, identity, &user)
+	})
+	switch {
+	case errors.Is(err, errOIDCEmailMissing), errors.Is(err, errOIDCEmailUnverified), errors.Is(err, errOIDCSignupDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete login"})
+		return
+	}
+
+	completeLogin(c, &user)
+}
+
+// consumeOIDCLoginState marks the login state as used and returns it
+func consumeOIDCLoginState(db *gorm.DB, provider, state string) (*models.OIDCLoginState, error) {
+	var loginState models.OIDCLoginState
+	err := db.Where("state_hash = ? AND provider = ? AND expires_at > ?", auth.HashToken(state), provider, time.Now()).
+		First(&loginState).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errOIDCLoginState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := db.Delete(&models.OIDCLoginState{}, loginState.ID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errOIDCLoginState
+	}
+	return &loginState, nil
+}
+
+// resolveOIDCUser finds the user linked to the identity. Unlinked identities
+// are linked to the user with the same verified email, or provisioned as a
+// new user when the provider allows signups.
+func resolveOIDCUser(tx *gorm.DB, ip string, provider *oidc.Provider, identity *oidc.Identity, user *models.User) error {
+	now := time.Now()
+
+	var link models.UserIdentity
+	err := tx.Where("provider = ? AND subject = ?", provider.Name, identity.Subject).First(&link).Error
+	if err == nil {
+		if err := tx.First(user, link.UserID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&link).Updates(map[string]interface{}{"email": identity.Email, "last_login_at": now}).Error; err != nil {
+			return err
+		}
+		return syncOIDCRole(tx, ip, provider, identity, user)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if identity.Email == "" {
+		return errOIDCEmailMissing
+	}
+
+	err = tx.Where("LOWER(email) = ?", identity.Email).First(user).Error
+	switch {
+	case err == nil:
+		// Linking by an unverified email would let anyone who can register
+		// that address at the provider take over the account
+		if !identity.EmailVerified {
+			return errOIDCEmailUnverified
+		}
+		if !user.EmailVerified {
+			user.EmailVerified = true
+			user.EmailVerifiedAt = &now
+			if err := tx.Save(user).Error; err != nil {
+				return err
+			}
+		}
+		service.RecordAudit(tx, "oidc.account_linked", &user.ID, &user.ID, ip, map[string]interface{}{
+			"provider": provider.Name,
+			"subject":  identity.Subject,
+		})
+
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if !provider.AllowSignup {
+			return errOIDCSignupDisabled
+		}
+		if err := provisionOIDCUser(tx, provider, identity, user); err != nil {
+			return err
+		}
+		service.RecordAudit(tx, "oidc.user_provisioned", nil, &user.ID, ip, map[string]interface{}{
+			"provider": provider.Name,
+			"subject":  identity.Subject,
+			"role":     user.Role,
+		})
+
+	default:
+		return err
+	}
+
+	return tx.Create(&models.UserIdentity{
+		UserID:      user.ID,
+		Provider:    provider.Name,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		LastLoginAt: now,
+	}).Error
+}
+
+// provisionOIDCUser creates a user for an identity that is not yet known.
+// The random password can be replaced through the password reset flow.
+func provisionOIDCUser(tx *gorm.DB, provider *oidc.Provider, identity *oidc.Identity, user *models.User) error {
+	password, _, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	role, err := oidcRole(provider, identity)
+	if err != nil {
+		return err
+	}
+
+	*user = models.User{
+		Name:          identity.Name,
+		Email:         identity.Email,
+		Password:      password,
+		Role:          role,
+		EmailVerified: identity.EmailVerified,
+	}
+	if identity.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	return tx.Create(user).Error
+}
+
+// syncOIDCRole re-applies the provider's role mapping to a linked user when
+// the provider is configured to keep roles in sync
+func syncOIDCRole(tx *gorm.DB, ip string, provider *oidc.Provider, identity *oidc.Identity, user *models.User) error {
+	if !provider.SyncRole || provider.RoleFor(identity) == "" {
+		return nil
+	}
+
+	role, err := oidcRole(provider, identity)
+	if err != nil || role == user.Role {
+		return err
+	}
+
+	previous := user.Role
+	user.Role = role
+	if err := tx.Model(user).Update("role", role).Error; err != nil {
+		return err
+	}
+	if err := service.InvalidateUserTokens(tx, user.ID); err != nil {
+		return err
+	}
+
+	service.RecordAudit(tx, "oidc.role_synced", nil, &user.ID, ip, map[string]interface{}{
+		"provider": provider.Name,
+		"from":     previous,
+		"to":       role,
+	})
+	return nil
+}
+
+// oidcRole returns the role mapped from the identity's claims, falling back
+// to the provider's default role when nothing matches or the role is unknown
+func oidcRole(provider *oidc.Provider, identity *oidc.Identity) (string, error) {
+	for _, role := range []string{provider.RoleFor(identity), provider.DefaultRole} {
+		if role == "" {
+			continue
+		}
+		exists, err := service.RoleExists(role)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return role, nil
+		}
+		log.Printf("OIDC provider %s maps to unknown role %q", provider.Name, role)
+	}
+	return "user", nil
+}
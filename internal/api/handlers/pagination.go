@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// listOptions describes how a list endpoint can be sorted
+type listOptions struct {
+	// table qualifies columns so that lists can join other tables
+	table string
+	// sorts maps the values accepted by the sort parameter to columns
+	sorts map[string]sortField
+	// defaultSort is used without a sort parameter, e.g. "-created"
+	defaultSort string
+}
+
+type sortField struct {
+	column string
+	time   bool
+}
+
+// contentSorts are the sort fields shared by content lists
+var contentSorts = map[string]sortField{
+	"created": {column: "created_at", time: true},
+	"updated": {column: "updated_at", time: true},
+}
+
+// listCursor marks the position after the last item of a cursor page
+type listCursor struct {
+	Sort  string      `json:"s"`
+	Value interface{} `json:"v"`
+	ID    uint        `json:"id"`
+}
+
+// paginate runs the list query into dest, a pointer to a slice, one page at
+// a time. Pages are selected with page and limit, or with cursor for stable
+// paging through large lists (an empty cursor starts at the beginning). The
+// total count and links to other pages are returned in the X-Total-Count and
+// Link headers. On failure a response is written and false is returned.
+func paginate(c *gin.Context, query *gorm.DB, opts listOptions, dest interface{}) bool {
+	page, limit, ok := pageParams(c)
+	if !ok {
+		return false
+	}
+
+	sort := c.DefaultQuery("sort", opts.defaultSort)
+	field, ok := opts.sorts[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort field: " + sort})
+		return false
+	}
+	direction, comparison := "ASC", ">"
+	if strings.HasPrefix(sort, "-") {
+		direction, comparison = "DESC", "<"
+	}
+	column := opts.table + "." + field.column
+	idColumn := opts.table + ".id"
+
+	total, ok := countResults(c, query)
+	if !ok {
+		return false
+	}
+
+	query = query.Order(fmt.Sprintf("%s %s, %s %s", column, direction, idColumn, direction))
+
+	cursorParam, cursorMode := c.GetQuery("cursor")
+	if !cursorMode {
+		if err := query.Offset((page - 1) * limit).Limit(limit).Find(dest).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch results"})
+			return false
+		}
+		setPageLinks(c, page, limit, total)
+		return true
+	}
+
+	if cursorParam != "" {
+		cursor, err := decodeCursor(cursorParam, sort, field)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return false
+		}
+		query = query.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", column, idColumn, comparison), cursor.Value, cursor.ID)
+	}
+
+	// Fetch one extra item to find out whether there is a next page
+	result := query.Limit(limit + 1).Find(dest)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch results"})
+		return false
+	}
+
+	items := reflect.ValueOf(dest).Elem()
+	if items.Len() > limit {
+		items.Set(items.Slice(0, limit))
+
+		last := items.Index(limit - 1)
+		schema := result.Statement.Schema
+		value, _ := schema.LookUpField(field.column).ValueOf(c.Request.Context(), last)
+		id, _ := schema.PrioritizedPrimaryField.ValueOf(c.Request.Context(), last)
+
+		next, err := encodeCursor(listCursor{Sort: sort, Value: value, ID: id.(uint)})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch results"})
+			return false
+		}
+		c.Header("Link", pageLink(c, "next", "cursor", next))
+	}
+
+	return true
+}
+
+// pageParams parses the page and limit query parameters. On failure a
+// response is written and false is returned.
+func pageParams(c *gin.Context) (page, limit int, ok bool) {
+	limit, err := queryInt(c, "limit", defaultPageLimit)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageLimit)})
+		return 0, 0, false
+	}
+	page, err = queryInt(c, "page", 1)
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive number"})
+		return 0, 0, false
+	}
+	return page, limit, true
+}
+
+// countResults counts the rows of the list query and returns the count in
+// the X-Total-Count header
+func countResults(c *gin.Context, query *gorm.DB) (int64, bool) {
+	var total int64
+	if err := query.Session(&gorm.Session{NewDB: true}).
+		Table("(?) AS list", query.Session(&gorm.Session{})).
+		Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count results"})
+		return 0, false
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	return total, true
+}
+
+// setPageLinks returns links to the first, last and neighbouring pages in
+// the Link header
+func setPageLinks(c *gin.Context, page, limit int, total int64) {
+	lastPage := int(math.Max(1, math.Ceil(float64(total)/float64(limit))))
+	links := []string{pageLink(c, "first", "page", "1"), pageLink(c, "last", "page", strconv.Itoa(lastPage))}
+	if page > 1 {
+		links = append(links, pageLink(c, "prev", "page", strconv.Itoa(page-1)))
+	}
+	if page < lastPage {
+		links = append(links, pageLink(c, "next", "page", strconv.Itoa(page+1)))
+	}
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+// encodeCursor returns the cursor as an opaque query parameter value
+func encodeCursor(cursor listCursor) (string, error) {
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor parses a cursor and checks that it was issued for the sort
+func decodeCursor(s, sort string, field sortField) (*listCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor listCursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.Sort != sort {
+		return nil, fmt.Errorf("cursor was issued for sort %q", cursor.Sort)
+	}
+
+	if field.time {
+		value, ok := cursor.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid cursor value")
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, err
+		}
+		cursor.Value = t
+	}
+
+	return &cursor, nil
+}
+
+// pageLink returns a Link header entry for the current request with one
+// query parameter replaced
+func pageLink(c *gin.Context, rel, param, value string) string {
+	u := *c.Request.URL
+	q := u.Query()
+	q.Set(param, value)
+	u.RawQuery = q.Encode()
+	return fmt.Sprintf("<%s>; rel=\"%s\"", u.RequestURI(), rel)
+}
+
+// queryInt parses an integer query parameter
+func queryInt(c *gin.Context, key string, def int) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// queryTime parses a date or RFC 3339 timestamp query parameter. Dates
+// refer to the start of the day in UTC, or to its last moment with endOfDay
+// so that ranges include the whole day.
+func queryTime(c *gin.Context, key string, endOfDay bool) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a date or RFC 3339 timestamp", key)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Microsecond)
+	}
+	return &t, nil
+}
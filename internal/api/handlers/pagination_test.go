@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	created := time.Date(2026, 3, 14, 15, 9, 26, 535897000, time.UTC)
+
+	tests := []struct {
+		name   string
+		cursor listCursor
+		field  sortField
+		want   interface{}
+	}{
+		{"time", listCursor{Sort: "-created", Value: created, ID: 42}, contentSorts["created"], created},
+		{"string", listCursor{Sort: "title", Value: "Merhaba dünya", ID: 7}, sortField{column: "title"}, "Merhaba dünya"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := encodeCursor(tt.cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := decodeCursor(s, tt.cursor.Sort, tt.field)
+			if err != nil {
+				t.Fatalf("decodeCursor(%q): %v", s, err)
+			}
+			if got.ID != tt.cursor.ID || got.Sort != tt.cursor.Sort {
+				t.Errorf("decoded %+v, want %+v", got, tt.cursor)
+			}
+			if want, ok := tt.want.(time.Time); ok {
+				if value, ok := got.Value.(time.Time); !ok || !value.Equal(want) {
+					t.Errorf("value = %v, want %v", got.Value, want)
+				}
+			} else if got.Value != tt.want {
+				t.Errorf("value = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	valid, err := encodeCursor(listCursor{Sort: "-created", Value: time.Now(), ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+		sort   string
+	}{
+		{"other sort", valid, "created"},
+		{"not base64", "not a cursor!", "-created"},
+		{"not JSON", encode("cursor"), "-created"},
+		{"time as number", encode(`{"s":"-created","v":1,"id":1}`), "-created"},
+		{"invalid time", encode(`{"s":"-created","v":"yesterday","id":1}`), "-created"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.cursor, tt.sort, contentSorts["created"]); err == nil {
+				t.Error("decodeCursor succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestPageParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		query     string
+		wantPage  int
+		wantLimit int
+		wantOK    bool
+	}{
+		{"", 1, defaultPageLimit, true},
+		{"page=3&limit=50", 3, 50, true},
+		{"limit=100", 1, 100, true},
+		{"limit=101", 0, 0, false},
+		{"limit=0", 0, 0, false},
+		{"page=0", 0, 0, false},
+		{"page=two", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/posts?"+tt.query, nil)
+
+		page, limit, ok := pageParams(c)
+		if page != tt.wantPage || limit != tt.wantLimit || ok != tt.wantOK {
+			t.Errorf("pageParams(%q) = (%d, %d, %v), want (%d, %d, %v)", tt.query, page, limit, ok, tt.wantPage, tt.wantLimit, tt.wantOK)
+		}
+		if !ok && w.Code != http.StatusBadRequest {
+			t.Errorf("pageParams(%q) responded %d, want %d", tt.query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSetPageLinks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		page  int
+		total int64
+		want  string
+	}{
+		{1, 0, `</posts?limit=10&page=1>; rel="first", </posts?limit=10&page=1>; rel="last"`},
+		{1, 25, `</posts?limit=10&page=1>; rel="first", </posts?limit=10&page=3>; rel="last", </posts?limit=10&page=2>; rel="next"`},
+		{2, 25, `</posts?limit=10&page=1>; rel="first", </posts?limit=10&page=3>; rel="last", </posts?limit=10&page=1>; rel="prev", </posts?limit=10&page=3>; rel="next"`},
+		{3, 30, `</posts?limit=10&page=1>; rel="first", </posts?limit=10&page=3>; rel="last", </posts?limit=10&page=2>; rel="prev"`},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/posts?limit=10", nil)
+
+		setPageLinks(c, tt.page, 10, tt.total)
+		if got := w.Header().Get("Link"); got != tt.want {
+			t.Errorf("page %d of %d items: Link = %s, want %s", tt.page, tt.total, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/auth"
+	"github.com/truncgil/gorecta/pkg/database"
+	"github.com/truncgil/gorecta/pkg/mailer"
+	"gorm.io/gorm"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// @Summary Request a password reset
+// @Description Send a password reset link to the given email. The response is the same
+// @Description whether or not the email is registered.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Account email"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/forgot-password [post]
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Do the lookup and delivery in the background so that response time
+	// does not reveal whether the email is registered
+	go sendPasswordReset(req.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a reset link has been sent"})
+}
+
+// @Summary Reset password
+// @Description Set a new password using a token from a reset email. Tokens are single-use
+// @Description and all existing sessions of the user are signed out.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/reset-password [post]
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var resetToken models.PasswordResetToken
+	if err := db.Where("token_hash = ?", auth.HashToken(req.Token)).First(&resetToken).Error; err != nil ||
+		resetToken.UsedAt != nil || time.Now().After(resetToken.ExpiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, resetToken.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	if !checkPasswordPolicy(c, req.Password, user.Email, user.Name) {
+		return
+	}
+
+	if err := user.UpdatePassword(req.Password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Consume the token; losing this race means it was already used
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", resetToken.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errResetTokenUsed
+		}
+
+		if err := tx.Model(&user).Update("password", user.Password).Error; err != nil {
+			return err
+		}
+		return service.InvalidateUserTokens(tx, user.ID)
+	})
+	if errors.Is(err, errResetTokenUsed) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+var errResetTokenUsed = errors.New("password reset token already used")
+
+// sendPasswordReset issues a reset token for the user with the given email,
+// if any, and mails them a reset link
+func sendPasswordReset(email string) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil || !user.IsActive() {
+		return
+	}
+
+	token, tokenHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		log.Printf("Failed to generate password reset token: %v", err)
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Only the most recently requested link stays valid
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: tokenHash,
+			ExpiresAt: time.Now().Add(passwordResetTTL()),
+		}).Error
+	})
+	if err != nil {
+		log.Printf("Failed to store password reset token: %v", err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", os.Getenv("APP_URL"), url.QueryEscape(token))
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nUse the link below to reset your password. It expires in %s.\n\n%s\n\n"+
+			"If you did not request a password reset, you can ignore this email.",
+			user.Name, passwordResetTTL(), link),
+	}
+	if err := mailer.GetMailer().Send(msg); err != nil {
+		log.Printf("Failed to send password reset email: %v", err)
+	}
+}
+
+// checkPasswordPolicy validates a new password and writes a 400 response
+// listing the broken rules if it is rejected
+func checkPasswordPolicy(c *gin.Context, password, email, name string) bool {
+	err := auth.ValidatePassword(password, email, name)
+	if err == nil {
+		return true
+	}
+
+	var policyErr *auth.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "Password does not meet the password policy",
+			"violations": policyErr.Violations,
+		})
+	} else {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check password"})
+	}
+	return false
+}
+
+// passwordResetTTL returns how long password reset links stay valid
+func passwordResetTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_EXPIRATION"))
+	if err != nil || ttl <= 0 {
+		return time.Hour
+	}
+	return ttl
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckPasswordPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("PASSWORD_MIN_LENGTH", "10")
+	t.Setenv("PASSWORD_REQUIRED_CLASSES", "digit")
+	t.Setenv("PASSWORD_DISALLOW_PERSONAL_INFO", "true")
+	t.Setenv("PASSWORD_BREACH_DIR", "")
+
+	tests := []struct {
+		name           string
+		password       string
+		wantOK         bool
+		wantViolations []string
+	}{
+		{"valid", "correct horse 7", true, nil},
+		{"too short", "short7", false, []string{"must be at least 10 characters"}},
+		{"personal info", "jane-secret-7", false, []string{"must not contain your email or name"}},
+		{
+			name:           "several violations",
+			password:       "jane",
+			wantViolations: []string{"must be at least 10 characters", "must contain a digit", "must not contain your email or name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			ok := checkPasswordPolicy(c, tt.password, "jane@example.com", "Jane Doe")
+			if ok != tt.wantOK {
+				t.Fatalf("checkPasswordPolicy = %v, want %v", ok, tt.wantOK)
+			}
+			if ok {
+				if w.Body.Len() != 0 {
+					t.Errorf("wrote a response for an accepted password: %s", w.Body)
+				}
+				return
+			}
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			var body struct {
+				Error      string   `json:"error"`
+				Violations []string `json:"violations"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(body.Violations, tt.wantViolations) {
+				t.Errorf("violations = %q, want %q", body.Violations, tt.wantViolations)
+			}
+		})
+	}
+}
+
+// An unreadable breached password list is logged and does not block passwords
+func TestCheckPasswordPolicyUnreadableBreachList(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	// A directory where the prefix file should be cannot be read as a list
+	if err := os.Mkdir(filepath.Join(dir, "5BAA6"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PASSWORD_MIN_LENGTH", "8")
+	t.Setenv("PASSWORD_REQUIRED_CLASSES", "")
+	t.Setenv("PASSWORD_BREACH_DIR", dir)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	if !checkPasswordPolicy(c, "password", "jane@example.com", "Jane Doe") {
+		t.Errorf("password was rejected with %d: %s", w.Code, w.Body)
+	}
+}
@@ -1,26 +1,43 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/policy"
+	"github.com/truncgil/gorecta/internal/service"
 	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
 )
 
 type CreatePostRequest struct {
 	Title       string `json:"title" binding:"required"`
 	Content     string `json:"content" binding:"required"`
-	Slug        string `json:"slug" binding:"required"`
 	CategoryID  uint   `json:"category_id" binding:"required"`
 	TagIDs      []uint `json:"tag_ids"`
 	FeaturedImg string `json:"featured_img"`
-	Published   bool   `json:"published"`
+	// Slug is generated from the title when empty
+	Slug string `json:"slug"`
+}
+
+// UpdatePostRequest holds the editable fields of a post. The status only
+// changes through workflow transitions.
+type UpdatePostRequest struct {
+	Title       string `json:"title" binding:"required"`
+	Content     string `json:"content" binding:"required"`
+	CategoryID  uint   `json:"category_id" binding:"required"`
+	TagIDs      []uint `json:"tag_ids"`
+	FeaturedImg string `json:"featured_img"`
+	// Slug keeps the current slug when empty
+	Slug string `json:"slug"`
 }
 
 // @Summary Create a new post
-// @Description Create a new blog post with the provided details
+// @Description Create a new blog post with the provided details. Without a slug, one is generated
+// @Description from the title, with a numeric suffix if it is taken.
 // @Tags posts
 // @Accept json
 // @Produce json
@@ -29,6 +46,8 @@ type CreatePostRequest struct {
 // @Success 200 {object} models.Post
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /posts [post]
 func CreatePost(c *gin.Context) {
@@ -43,65 +62,179 @@ func CreatePost(c *gin.Context) {
 	post := models.Post{
 		Title:       req.Title,
 		Content:     req.Content,
-		Slug:        req.Slug,
 		CategoryID:  req.CategoryID,
 		UserID:      userID.(uint),
 		FeaturedImg: req.FeaturedImg,
-		Published:   req.Published,
+		Status:      models.PostStatusDraft,
 	}
 
 	db := database.GetDB()
-	if err := db.Create(&post).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post"})
+	if !checkCategory(c, db, post.CategoryID) {
 		return
 	}
 
-	// Add tags if provided
-	if len(req.TagIDs) > 0 {
-		var tags []models.Tag
-		if err := db.Find(&tags, req.TagIDs).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find tags"})
-			return
+	slug, ok := chooseSlug(c, db, "posts", req.Slug, req.Title, 0)
+	if !ok {
+		return
+	}
+	post.Slug = slug
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&post).Error; err != nil {
+			return err
 		}
-		if err := db.Model(&post).Association("Tags").Replace(tags); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add tags"})
-			return
+
+		// Add tags if provided
+		if len(req.TagIDs) > 0 {
+			if err := replacePostTags(tx, &post, req.TagIDs); err != nil {
+				return err
+			}
 		}
+
+		_, err := service.RecordPostRevision(tx, post.ID, post.UserID, nil)
+		return err
+	})
+	if err != nil {
+		if !slugConflict(c, db, "posts", post.Slug, 0) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post"})
+		}
+		return
 	}
 
 	c.JSON(http.StatusCreated, post)
 }
 
 // @Summary Get all posts
-// @Description Get a list of all blog posts
+// @Description Get a page of blog posts. The total count is returned in the X-Total-Count header
+// @Description and links to other pages in the Link header.
 // @Tags posts
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Posts per page (max 100)" default(20)
+// @Param cursor query string false "Cursor from a previous Link header; empty to start cursor paging"
+// @Param sort query string false "created, updated or title; prefix with - for descending" default(-created)
+// @Param author_id query int false "Filter by author"
+// @Param category_id query int false "Filter by category ID"
+// @Param category query string false "Filter by category slug"
+// @Param tag query string false "Filter by tag slug"
+// @Param from query string false "Created on or after this date or timestamp"
+// @Param to query string false "Created on or before this date or timestamp"
+// @Param published query bool false "Filter by published state"
+// @Param status query string false "Filter by status: draft, in_review, approved, scheduled, published or archived"
+// @Param reviewer_id query int false "Filter by assigned reviewer"
 // @Success 200 {array} models.Post
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /posts [get]
 func GetPosts(c *gin.Context) {
 	var posts []models.Post
-	db := database.GetDB()
 
-	query := db.Preload("User").Preload("Category").Preload("Tags")
+	query, ok := filterPosts(c, database.GetDB().Model(&models.Post{}).Preload("User").Preload("Category").Preload("Tags"))
+	if !ok {
+		return
+	}
+
+	if !paginate(c, query, postListOptions, &posts) {
+		return
+	}
+
+	c.JSON(http.StatusOK, posts)
+}
+
+// filterPosts applies the post list filters of the request to query and
+// hides drafts the user may not read. On failure a response is written and
+// false is returned.
+func filterPosts(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	db := database.GetDB()
 
-	// Apply filters
 	if published := c.Query("published"); published != "" {
-		query = query.Where("published = ?", published == "true")
+		query = query.Where("posts.published = ?", published == "true")
+	}
+
+	if status := c.Query("status"); status != "" {
+		if !models.IsPostStatus(status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post status"})
+			return nil, false
+		}
+		query = query.Where("posts.status = ?", status)
 	}
 
 	if categoryID := c.Query("category_id"); categoryID != "" {
-		query = query.Where("category_id = ?", categoryID)
+		query = query.Where("posts.category_id = ?", categoryID)
 	}
 
-	if err := query.Find(&posts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
-		return
+	if category := c.Query("category"); category != "" {
+		query = query.Where("posts.category_id IN (?)", db.Model(&models.Category{}).Select("id").Where("slug = ?", category))
 	}
 
-	c.JSON(http.StatusOK, posts)
+	if tag := c.Query("tag"); tag != "" {
+		query = query.Where("posts.id IN (?)", db.Table("post_tags").
+			Select("post_tags.post_id").
+			Joins("JOIN tags ON tags.id = post_tags.tag_id").
+			Where("tags.slug = ? AND tags.deleted_at IS NULL", tag))
+	}
+
+	if reviewerID := c.Query("reviewer_id"); reviewerID != "" {
+		id, err := strconv.ParseUint(reviewerID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reviewer ID"})
+			return nil, false
+		}
+		query = query.Where("posts.reviewer_id = ?", id)
+	}
+
+	if authorID := c.Query("author_id"); authorID != "" {
+		id, err := strconv.ParseUint(authorID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
+			return nil, false
+		}
+		query = query.Where("posts.user_id = ?", id)
+	}
+
+	from, err := queryTime(c, "from", false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if from != nil {
+		query = query.Where("posts.created_at >= ?", *from)
+	}
+
+	to, err := queryTime(c, "to", true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if to != nil {
+		query = query.Where("posts.created_at <= ?", *to)
+	}
+
+	// Drafts are only listed for their authors unless the user may edit any post
+	subject := currentSubject(c)
+	allDrafts, err := policy.CanReadAllDrafts(subject, "posts")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return nil, false
+	}
+	if !allDrafts {
+		query = query.Where("posts.published = ? OR posts.user_id = ?", true, subject.UserID)
+	}
+
+	return query, true
+}
+
+// postListOptions are the sort fields of post lists
+var postListOptions = listOptions{
+	table: "posts",
+	sorts: map[string]sortField{
+		"created": contentSorts["created"],
+		"updated": contentSorts["updated"],
+		"title":   {column: "title"},
+	},
+	defaultSort: "-created",
 }
 
 // @Summary Get a post by ID
@@ -116,29 +249,52 @@ func GetPosts(c *gin.Context) {
 // @Failure 500 {object} map[string]string
 // @Router /posts/{id} [get]
 func GetPost(c *gin.Context) {
-	id := c.Param("id")
-	var post models.Post
-
-	if err := database.GetDB().Preload("User").Preload("Category").Preload("Tags").First(&post, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+	post, ok := readablePost(c, "posts.id = ?", c.Param("id"))
+	if !ok {
 		return
 	}
 
 	c.JSON(http.StatusOK, post)
 }
 
+// readablePost loads the post matching the condition with its relations. On
+// failure, including when the user may not read the post, a response is
+// written and false is returned.
+func readablePost(c *gin.Context, query string, args ...interface{}) (*models.Post, bool) {
+	var post models.Post
+	if err := database.GetDB().Preload("User").Preload("Category").Preload("Tags").Preload("Reviewer").
+		Where(query, args...).First(&post).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+
+	// Hide drafts the user may not read instead of revealing they exist
+	if err := policy.Authorize(currentSubject(c), policy.ActionRead, &post); err != nil {
+		if errors.Is(err, policy.ErrForbidden) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		}
+		return nil, false
+	}
+	return &post, true
+}
+
 // @Summary Update a post
-// @Description Update an existing blog post
+// @Description Update an existing blog post. A changed slug is kept in the post's slug history
+// @Description so that the former slug redirects to the new one.
 // @Tags posts
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Post ID"
-// @Param request body CreatePostRequest true "Post update details"
+// @Param request body UpdatePostRequest true "Post update details"
 // @Success 200 {object} models.Post
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /posts/{id} [put]
 func UpdatePost(c *gin.Context) {
@@ -148,7 +304,7 @@ func UpdatePost(c *gin.Context) {
 		return
 	}
 
-	var req CreatePostRequest
+	var req UpdatePostRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -162,37 +318,105 @@ func UpdatePost(c *gin.Context) {
 		return
 	}
 
+	if !authorize(c, policy.ActionUpdate, &post) {
+		return
+	}
+
+	if !checkCategory(c, db, req.CategoryID) {
+		return
+	}
+
+	oldSlug := post.Slug
+	if req.Slug != "" {
+		slug, ok := chooseSlug(c, db, "posts", req.Slug, req.Title, post.ID)
+		if !ok {
+			return
+		}
+		post.Slug = slug
+	}
+
 	// Update post fields
 	post.Title = req.Title
 	post.Content = req.Content
-	post.Slug = req.Slug
 	post.CategoryID = req.CategoryID
 	post.FeaturedImg = req.FeaturedImg
-	post.Published = req.Published
 
-	if err := db.Save(&post).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
-		return
-	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := service.EnsureBaseRevision(tx, post.ID); err != nil {
+			return err
+		}
 
-	// Update tags if provided
-	if len(req.TagIDs) > 0 {
-		var tags []models.Tag
-		if err := db.Find(&tags, req.TagIDs).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find tags"})
-			return
+		if err := tx.Save(&post).Error; err != nil {
+			return err
 		}
-		if err := db.Model(&post).Association("Tags").Replace(tags); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tags"})
-			return
+		if err := service.RecordSlugChange(tx, "posts", post.ID, oldSlug, post.Slug); err != nil {
+			return err
+		}
+
+		// Update tags if provided
+		if len(req.TagIDs) > 0 {
+			if err := replacePostTags(tx, &post, req.TagIDs); err != nil {
+				return err
+			}
+		}
+
+		_, err := service.RecordPostRevision(tx, post.ID, currentUserID(c), nil)
+		return err
+	})
+	if err != nil {
+		if !slugConflict(c, db, "posts", post.Slug, post.ID) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
 		}
+		return
 	}
 
 	c.JSON(http.StatusOK, post)
 }
 
+// checkCategory checks that the category exists and is not in the trash. On
+// failure a response is written and false is returned.
+func checkCategory(c *gin.Context, db *gorm.DB, categoryID uint) bool {
+	var count int64
+	if err := db.Model(&models.Category{}).Where("id = ?", categoryID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check category"})
+		return false
+	}
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category not found"})
+		return false
+	}
+	return true
+}
+
+// replacePostTags sets the tags of the post to the existing tags among ids.
+// Links to tags in the trash are kept, so they reappear when the tag is
+// restored.
+func replacePostTags(tx *gorm.DB, post *models.Post, ids []uint) error {
+	tags := []models.Tag{}
+	if len(ids) > 0 {
+		if err := tx.Find(&tags, ids).Error; err != nil {
+			return err
+		}
+	}
+
+	var trashed []models.Tag
+	if err := tx.Unscoped().Select("tags.*").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Where("post_tags.post_id = ? AND tags.deleted_at IS NOT NULL", post.ID).
+		Find(&trashed).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(post).Association("Tags").Replace(append(tags, trashed...)); err != nil {
+		return err
+	}
+	post.Tags = tags
+	return nil
+}
+
 // @Summary Delete a post
-// @Description Delete a blog post by its ID
+// @Description Move a blog post to the trash. It keeps its tags, revisions and workflow history
+// @Description and can be restored until it is purged.
 // @Tags posts
 // @Accept json
 // @Produce json
@@ -200,6 +424,7 @@ func UpdatePost(c *gin.Context) {
 // @Param id path int true "Post ID"
 // @Success 200 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /posts/{id} [delete]
@@ -213,9 +438,7 @@ func DeletePost(c *gin.Context) {
 		return
 	}
 
-	// Delete associated tags
-	if err := db.Model(&post).Association("Tags").Clear(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete post tags"})
+	if !authorize(c, policy.ActionDelete, &post) {
 		return
 	}
 
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/policy"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/database"
+	"github.com/truncgil/gorecta/pkg/diff"
+	"gorm.io/gorm"
+)
+
+// FieldChange is a post field that differs between two revisions
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// RevisionDiff describes the changes between two revisions of a post
+type RevisionDiff struct {
+	From    int                    `json:"from"`
+	To      int                    `json:"to"`
+	Mode    string                 `json:"mode"`
+	Title   []diff.Op              `json:"title"`
+	Content []diff.Op              `json:"content"`
+	Changes map[string]FieldChange `json:"changes"`
+}
+
+// @Summary List post revisions
+// @Description List the revisions of a post, newest first. Content is omitted; fetch a single
+// @Description revision to get it.
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Post ID"
+// @Success 200 {array} models.PostRevision
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/{id}/revisions [get]
+func GetPostRevisions(c *gin.Context) {
+	post, ok := revisionPost(c)
+	if !ok {
+		return
+	}
+
+	var revisions []models.PostRevision
+	if err := database.GetDB().Preload("User").
+		Omit("content").
+		Where("post_id = ?", post.ID).
+		Order("number DESC").
+		Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// @Summary Get a post revision
+// @Description Get a revision of a post by its number
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Post ID"
+// @Param number path int true "Revision number"
+// @Success 200 {object} models.PostRevision
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /posts/{id}/revisions/{number} [get]
+func GetPostRevision(c *gin.Context) {
+	post, ok := revisionPost(c)
+	if !ok {
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision number"})
+		return
+	}
+
+	revision, ok := findRevision(c, post.ID, number)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
+
+// @Summary Compare post revisions
+// @Description Diff two revisions of a post. Title and content are compared line by line or
+// @Description word by word; other fields are listed when they changed.
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Post ID"
+// @Param from query int true "Older revision number"
+// @Param to query int false "Newer revision number; defaults to the latest revision"
+// @Param mode query string false "line or word" default(line)
+// @Success 200 {object} RevisionDiff
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/{id}/revisions/diff [get]
+func DiffPostRevisions(c *gin.Context) {
+	post, ok := revisionPost(c)
+	if !ok {
+		return
+	}
+
+	mode := c.DefaultQuery("mode", "line")
+	diffText := diff.Lines
+	switch mode {
+	case "line":
+	case "word":
+		diffText = diff.Words
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be line or word"})
+		return
+	}
+
+	fromNumber, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from revision number"})
+		return
+	}
+
+	db := database.GetDB()
+	var toNumber int
+	if to := c.Query("to"); to != "" {
+		toNumber, err = strconv.Atoi(to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to revision number"})
+			return
+		}
+	} else if err := db.Model(&models.PostRevision{}).Where("post_id = ?", post.ID).
+		Select("COALESCE(MAX(number), 0)").Scan(&toNumber).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+
+	from, ok := findRevision(c, post.ID, fromNumber)
+	if !ok {
+		return
+	}
+	to, ok := findRevision(c, post.ID, toNumber)
+	if !ok {
+		return
+	}
+
+	changes := map[string]FieldChange{}
+	if from.Slug != to.Slug {
+		changes["slug"] = FieldChange{From: from.Slug, To: to.Slug}
+	}
+	if from.Status != to.Status {
+		changes["status"] = FieldChange{From: from.Status, To: to.Status}
+	}
+	if !sameTime(from.PublishAt, to.PublishAt) {
+		changes["publish_at"] = FieldChange{From: from.PublishAt, To: to.PublishAt}
+	}
+	if !sameTime(from.UnpublishAt, to.UnpublishAt) {
+		changes["unpublish_at"] = FieldChange{From: from.UnpublishAt, To: to.UnpublishAt}
+	}
+	if from.FeaturedImg != to.FeaturedImg {
+		changes["featured_img"] = FieldChange{From: from.FeaturedImg, To: to.FeaturedImg}
+	}
+	if from.CategoryID != to.CategoryID {
+		changes["category"] = FieldChange{From: from.Category, To: to.Category}
+	}
+	if !equalTagIDs(from.Tags.IDs(), to.Tags.IDs()) {
+		changes["tags"] = FieldChange{From: from.Tags, To: to.Tags}
+	}
+
+	c.JSON(http.StatusOK, RevisionDiff{
+		From:    from.Number,
+		To:      to.Number,
+		Mode:    mode,
+		Title:   diff.Words(from.Title, to.Title),
+		Content: diffText(from.Content, to.Content),
+		Changes: changes,
+	})
+}
+
+// @Summary Restore a post revision
+// @Description Restore the content, category and tags of a post from a revision. The status is
+// @Description left as it is; it only changes through workflow transitions. The restore is
+// @Description recorded as a new revision, so it can be undone as well. Categories and tags
+// @Description deleted since, and a slug another post has taken since, are left out.
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Post ID"
+// @Param number path int true "Revision number"
+// @Success 200 {object} models.Post
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/{id}/revisions/{number}/restore [post]
+func RestorePostRevision(c *gin.Context) {
+	post, ok := revisionPost(c)
+	if !ok {
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision number"})
+		return
+	}
+
+	revision, ok := findRevision(c, post.ID, number)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+
+	// Categories deleted since the revision cannot be restored
+	categoryID := revision.CategoryID
+	var count int64
+	if err := db.Model(&models.Category{}).Where("id = ?", categoryID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		return
+	}
+	if count == 0 {
+		categoryID = post.CategoryID
+	}
+
+	// Neither can slugs another post has taken since
+	oldSlug := post.Slug
+	taken, err := service.SlugTaken(db, "posts", revision.Slug, post.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		return
+	}
+	if !taken {
+		post.Slug = revision.Slug
+	}
+
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.FeaturedImg = revision.FeaturedImg
+	post.CategoryID = categoryID
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(post).Error; err != nil {
+			return err
+		}
+		if err := service.RecordSlugChange(tx, "posts", post.ID, oldSlug, post.Slug); err != nil {
+			return err
+		}
+		if err := replacePostTags(tx, post, revision.Tags.IDs()); err != nil {
+			return err
+		}
+
+		_, err := service.RecordPostRevision(tx, post.ID, currentUserID(c), &revision.Number)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		return
+	}
+
+	if err := db.Preload("User").Preload("Category").Preload("Tags").First(post, post.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch post"})
+		return
+	}
+
+	c.JSON(http.StatusOK, post)
+}
+
+// revisionPost loads the post of a revision request and checks that the user
+// may edit it, since revisions expose unpublished changes. On failure a
+// response is written and false is returned.
+func revisionPost(c *gin.Context) (*models.Post, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return nil, false
+	}
+
+	var post models.Post
+	if err := database.GetDB().First(&post, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+
+	if !authorize(c, policy.ActionUpdate, &post) {
+		return nil, false
+	}
+	return &post, true
+}
+
+// findRevision loads a revision of the post by its number. On failure a
+// response is written and false is returned.
+func findRevision(c *gin.Context, postID uint, number int) (*models.PostRevision, bool) {
+	var revision models.PostRevision
+	err := database.GetDB().Preload("User").
+		Where("post_id = ? AND number = ?", postID, number).
+		First(&revision).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revision"})
+		return nil, false
+	}
+	return &revision, true
+}
+
+// equalTagIDs reports whether two tag lists hold the same tags
+func equalTagIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[uint]bool, len(a))
+	for _, id := range a {
+		seen[id] = true
+	}
+	for _, id := range b {
+		if !seen[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// sameTime reports whether two optional times are equal
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
+)
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRoleRequest struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// @Summary Get all permissions
+// @Description Get a list of all permissions that can be assigned to roles
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Permission
+// @Failure 500 {object} map[string]string
+// @Router /permissions [get]
+func GetPermissions(c *gin.Context) {
+	var permissions []models.Permission
+	if err := database.GetDB().Order("name").Find(&permissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+// @Summary Get all roles
+// @Description Get a list of all roles with their permissions
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Role
+// @Failure 500 {object} map[string]string
+// @Router /roles [get]
+func GetRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := database.GetDB().Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// @Summary Get a role by ID
+// @Description Get a specific role with its permissions
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Success 200 {object} models.Role
+// @Failure 404 {object} map[string]string
+// @Router /roles/{id} [get]
+func GetRole(c *gin.Context) {
+	id := c.Param("id")
+	var role models.Role
+
+	if err := database.GetDB().Preload("Permissions").First(&role, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// @Summary Create a new role
+// @Description Create a custom role with the given permissions
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateRoleRequest true "Role creation details"
+// @Success 201 {object} models.Role
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /roles [post]
+func CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var existingRole models.Role
+	if err := db.Where("name = ?", req.Name).First(&existingRole).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role already exists"})
+		return
+	}
+
+	permissions, ok := findPermissions(c, req.Permissions)
+	if !ok {
+		return
+	}
+
+	role := models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+
+	if err := db.Create(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+
+	service.InvalidatePermissionCache()
+	c.JSON(http.StatusCreated, role)
+}
+
+// @Summary Update a role
+// @Description Update a role's description and replace its permissions. The admin role's
+// @Description permissions cannot be changed.
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Param request body UpdateRoleRequest true "Role update details"
+// @Success 200 {object} models.Role
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /roles/{id} [put]
+func UpdateRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var role models.Role
+	if err := db.First(&role, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	permissions, ok := findPermissions(c, req.Permissions)
+	if !ok {
+		return
+	}
+
+	role.Description = req.Description
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&role).Error; err != nil {
+			return err
+		}
+		// Admin keeps every permission so nobody can lock themselves out
+		if role.Name == service.AdminRole {
+			return nil
+		}
+		return tx.Model(&role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	service.InvalidatePermissionCache()
+
+	if err := db.Preload("Permissions").First(&role, role.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// @Summary Delete a role
+// @Description Delete a custom role. Built-in roles and roles still assigned to users cannot be deleted.
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /roles/{id} [delete]
+func DeleteRole(c *gin.Context) {
+	id := c.Param("id")
+	var role models.Role
+
+	db := database.GetDB()
+	if err := db.First(&role, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	if role.System {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Built-in roles cannot be deleted"})
+		return
+	}
+
+	var userCount int64
+	if err := db.Model(&models.User{}).Where("role = ?", role.Name).Count(&userCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count role users"})
+		return
+	}
+	if userCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role is still assigned to users", "user_count": userCount})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+
+	service.InvalidatePermissionCache()
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// findPermissions loads the named permissions, writing a 400 response and
+// returning false if any of them does not exist
+func findPermissions(c *gin.Context, names []string) ([]models.Permission, bool) {
+	permissions := []models.Permission{}
+	if len(names) == 0 {
+		return permissions, true
+	}
+
+	if err := database.GetDB().Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return nil, false
+	}
+
+	found := make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		found[p.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + name})
+			return nil, false
+		}
+	}
+
+	return permissions, true
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
+)
+
+// ts_headline options for the highlighted titles and content snippets of
+// search results
+const (
+	titleHeadlineOptions   = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+	contentHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=3, FragmentDelimiter=\" ... \""
+)
+
+type SearchSettingsRequest struct {
+	Language string `json:"language" binding:"required"`
+}
+
+// PostSearchResult is a post matching a search with its relevance and the
+// matching parts of its title and content highlighted
+type PostSearchResult struct {
+	models.Post
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet"`
+}
+
+// postMatch is a search hit before its post is loaded
+type postMatch struct {
+	ID             uint
+	Rank           float64
+	TitleHighlight string
+	Snippet        string
+}
+
+// @Summary Search posts
+// @Description Full-text search over post titles and content, ordered by relevance with title
+// @Description matches ranking first. Matches are highlighted with <mark> tags. Supports the
+// @Description filters of the post list; the total count is returned in the X-Total-Count header
+// @Description and links to other pages in the Link header.
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Search query; supports quoted phrases, OR and -exclusions"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Results per page (max 100)" default(20)
+// @Param category_id query int false "Filter by category ID"
+// @Param category query string false "Filter by category slug"
+// @Param tag query string false "Filter by tag slug"
+// @Param author_id query int false "Filter by author"
+// @Param from query string false "Created on or after this date or timestamp"
+// @Param to query string false "Created on or before this date or timestamp"
+// @Param published query bool false "Filter by published state"
+// @Param status query string false "Filter by status"
+// @Success 200 {array} PostSearchResult
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/search [get]
+func SearchPosts(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+
+	page, limit, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	language, err := service.SearchLanguage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search posts"})
+		return
+	}
+
+	db := database.GetDB()
+	query, ok := filterPosts(c, db.Model(&models.Post{}).
+		Joins("CROSS JOIN websearch_to_tsquery(?::regconfig, ?) AS search_query", language, q).
+		Where("posts.search_vector @@ search_query"))
+	if !ok {
+		return
+	}
+
+	total, ok := countResults(c, query.Session(&gorm.Session{}).Select("posts.id"))
+	if !ok {
+		return
+	}
+
+	var matches []postMatch
+	if err := query.Select(
+		"posts.id, ts_rank(posts.search_vector, search_query) AS rank, "+
+			"ts_headline(?::regconfig, posts.title, search_query, ?) AS title_highlight, "+
+			"ts_headline(?::regconfig, posts.content, search_query, ?) AS snippet",
+		language, titleHeadlineOptions, language, contentHeadlineOptions).
+		Order("rank DESC, posts.id DESC").
+		Offset((page - 1) * limit).Limit(limit).
+		Scan(&matches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search posts"})
+		return
+	}
+
+	results := make([]PostSearchResult, 0, len(matches))
+	if len(matches) > 0 {
+		ids := make([]uint, len(matches))
+		for i, match := range matches {
+			ids[i] = match.ID
+		}
+
+		var posts []models.Post
+		if err := db.Preload("User").Preload("Category").Preload("Tags").Find(&posts, ids).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search posts"})
+			return
+		}
+		byID := make(map[uint]models.Post, len(posts))
+		for _, post := range posts {
+			byID[post.ID] = post
+		}
+
+		// Keep the relevance order of the matches
+		for _, match := range matches {
+			if post, ok := byID[match.ID]; ok {
+				results = append(results, PostSearchResult{
+					Post:           post,
+					Rank:           match.Rank,
+					TitleHighlight: match.TitleHighlight,
+					Snippet:        match.Snippet,
+				})
+			}
+		}
+	}
+
+	setPageLinks(c, page, limit, total)
+	c.JSON(http.StatusOK, results)
+}
+
+// @Summary Get search settings
+// @Description Get the text search configuration used to index posts and the configurations
+// @Description the database supports
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/search-settings [get]
+func GetSearchSettings(c *gin.Context) {
+	language, err := service.SearchLanguage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch search settings"})
+		return
+	}
+
+	languages, err := service.SearchLanguages(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch search settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"language": language, "available_languages": languages})
+}
+
+// @Summary Update search settings
+// @Description Change the text search configuration used for post search, e.g. "english" or
+// @Description "turkish". The search index of all posts is rebuilt, which may take a while.
+// @Tags posts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body SearchSettingsRequest true "Text search configuration"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/search-settings [put]
+func UpdateSearchSettings(c *gin.Context) {
+	var req SearchSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	language := strings.ToLower(strings.TrimSpace(req.Language))
+
+	err := service.SetSearchLanguage(database.GetDB(), language)
+	if errors.Is(err, service.ErrUnknownSearchLanguage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown text search configuration"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update search settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"language": language})
+}
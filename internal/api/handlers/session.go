@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/database"
+)
+
+// sessionResponse is a session as listed to its user, flagging the session
+// the request was made with
+type sessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// @Summary List own sessions
+// @Description List the authenticated user's active sessions
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} sessionResponse
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/sessions [get]
+func GetSessions(c *gin.Context) {
+	listSessions(c, currentUserID(c))
+}
+
+// @Summary Revoke a session
+// @Description Sign out one of the authenticated user's sessions
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Session ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/sessions/{id} [delete]
+func RevokeSession(c *gin.Context) {
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	revokeSession(c, currentUserID(c), uint(sessionID))
+}
+
+// @Summary Revoke other sessions
+// @Description Sign out every session of the authenticated user except the current one
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/sessions [delete]
+func RevokeOtherSessions(c *gin.Context) {
+	revokeSessions(c, currentUserID(c), c.GetUint("session_id"))
+}
+
+// @Summary List a user's sessions
+// @Description List the active sessions of any user
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {array} sessionResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/sessions [get]
+func GetUserSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	listSessions(c, uint(id))
+}
+
+// @Summary Revoke a user's session
+// @Description Sign out one session of any user
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param session_id path int true "Session ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/sessions/{session_id} [delete]
+func RevokeUserSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	sessionID, err := strconv.ParseUint(c.Param("session_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	revokeSession(c, uint(id), uint(sessionID))
+}
+
+// @Summary Revoke all of a user's sessions
+// @Description Sign out every session of any user. The admin's own current session is kept.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/sessions [delete]
+func RevokeUserSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	keepID := uint(0)
+	if uint(id) == currentUserID(c) {
+		keepID = c.GetUint("session_id")
+	}
+	revokeSessions(c, uint(id), keepID)
+}
+
+func listSessions(c *gin.Context, userID uint) {
+	var sessions []models.Session
+	if err := database.GetDB().
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	currentID := c.GetUint("session_id")
+	response := make([]sessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = sessionResponse{Session: session, Current: session.ID == currentID}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func revokeSession(c *gin.Context, userID, sessionID uint) {
+	db := database.GetDB()
+
+	err := service.RevokeSession(db, userID, sessionID)
+	if errors.Is(err, service.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	actorID := currentUserID(c)
+	service.RecordAudit(db, "session.revoked", &actorID, &userID, c.ClientIP(), map[string]interface{}{
+		"session_id": sessionID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+func revokeSessions(c *gin.Context, userID, keepID uint) {
+	db := database.GetDB()
+
+	revoked, err := service.RevokeOtherSessions(db, userID, keepID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	actorID := currentUserID(c)
+	service.RecordAudit(db, "session.revoked_all", &actorID, &userID, c.ClientIP(), map[string]interface{}{
+		"revoked": revoked,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully", "revoked": revoked})
+}
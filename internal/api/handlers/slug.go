@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/database"
+	"github.com/truncgil/gorecta/pkg/slug"
+	"gorm.io/gorm"
+)
+
+// untitledSlug is generated for titles without letters or digits
+const untitledSlug = "untitled"
+
+// SlugRedirect points a former slug to the current one
+type SlugRedirect struct {
+	ID       uint   `json:"id"`
+	Slug     string `json:"slug"`
+	Location string `json:"location"`
+}
+
+// @Summary Resolve a post slug
+// @Description Get a post by its slug. Former slugs of a post answer with 301 and the post's
+// @Description current slug, also in the Location header.
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Param slug path string true "Post slug"
+// @Success 200 {object} models.Post
+// @Success 301 {object} SlugRedirect
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/slug/{slug} [get]
+func ResolvePostSlug(c *gin.Context) {
+	db := database.GetDB()
+
+	var count int64
+	if err := db.Model(&models.Post{}).Where("slug = ?", c.Param("slug")).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch post"})
+		return
+	}
+	if count > 0 {
+		post, ok := readablePost(c, "posts.slug = ?", c.Param("slug"))
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, post)
+		return
+	}
+
+	history, ok := formerSlug(c, db, "posts", "Post")
+	if !ok {
+		return
+	}
+
+	post, ok := readablePost(c, "posts.id = ?", history.ResourceID)
+	if !ok {
+		return
+	}
+	redirectSlug(c, post.ID, post.Slug)
+}
+
+// @Summary Resolve a category slug
+// @Description Get a category by its slug. Former slugs of a category answer with 301 and the
+// @Description category's current slug, also in the Location header.
+// @Tags categories
+// @Produce json
+// @Security BearerAuth
+// @Param slug path string true "Category slug"
+// @Success 200 {object} models.Category
+// @Success 301 {object} SlugRedirect
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /categories/slug/{slug} [get]
+func ResolveCategorySlug(c *gin.Context) {
+	db := database.GetDB()
+
+	var category models.Category
+	err := db.Where("slug = ?", c.Param("slug")).First(&category).Error
+	if err == nil {
+		c.JSON(http.StatusOK, category)
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch category"})
+		return
+	}
+
+	history, ok := formerSlug(c, db, "categories", "Category")
+	if !ok {
+		return
+	}
+
+	if err := db.First(&category, history.ResourceID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+	redirectSlug(c, category.ID, category.Slug)
+}
+
+// formerSlug looks up the slug in the path among the former slugs of the
+// resource type. On failure a response is written and false is returned.
+func formerSlug(c *gin.Context, db *gorm.DB, resourceType, name string) (*models.SlugHistory, bool) {
+	history, err := service.FindFormerSlug(db, resourceType, c.Param("slug"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": name + " not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch " + strings.ToLower(name)})
+		return nil, false
+	}
+	return history, true
+}
+
+// redirectSlug answers a request for a former slug with the current one
+func redirectSlug(c *gin.Context, id uint, current string) {
+	location := strings.TrimSuffix(c.Request.URL.Path, c.Param("slug")) + current
+	c.Header("Location", location)
+	c.JSON(http.StatusMovedPermanently, SlugRedirect{ID: id, Slug: current, Location: location})
+}
+
+// chooseSlug returns the slug for a post or category: the requested slug,
+// normalized, which must not be in use, or a slug generated from the title
+// with a numeric suffix if needed. id is the item being updated, or 0. On
+// failure a response is written and false is returned.
+func chooseSlug(c *gin.Context, db *gorm.DB, resourceType, requested, title string, id uint) (string, bool) {
+	if requested != "" {
+		s := slug.Make(requested)
+		if s == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Slug must contain letters or digits"})
+			return "", false
+		}
+
+		taken, err := service.SlugTaken(db, resourceType, s, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check slug"})
+			return "", false
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": "Slug is already in use", "slug": s})
+			return "", false
+		}
+		return s, true
+	}
+
+	base := slug.Make(title)
+	if base == "" {
+		base = untitledSlug
+	}
+	s, err := service.UniqueSlug(db, resourceType, base, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate slug"})
+		return "", false
+	}
+	return s, true
+}
+
+// slugConflict writes a conflict response and returns true if a failed write
+// was caused by another item taking the slug in the meantime
+func slugConflict(c *gin.Context, db *gorm.DB, resourceType, s string, id uint) bool {
+	taken, err := service.SlugTaken(db, resourceType, s, id)
+	if err != nil || !taken {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "Slug is already in use", "slug": s})
+	return true
+}
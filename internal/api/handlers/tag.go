@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/policy"
+	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
+)
+
+type CreateTagRequest struct {
+	Name string `json:"name" binding:"required"`
+	Slug string `json:"slug" binding:"required"`
+}
+
+// tagWithPostCount selects tags together with the number of posts using them
+func tagWithPostCount() *gorm.DB {
+	return database.GetDB().
+		Model(&models.Tag{}).
+		Select("tags.*, COUNT(posts.id) AS post_count").
+		Joins("LEFT JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("LEFT JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL").
+		Group("tags.id")
+}
+
+// @Summary Create a new tag
+// @Description Create a new tag with the provided details
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateTagRequest true "Tag creation details"
+// @Success 201 {object} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tags [post]
+func CreateTag(c *gin.Context) {
+	var req CreateTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	// Check if slug is already taken, including by tags in the trash
+	var existingTag models.Tag
+	if err := db.Unscoped().Where("slug = ?", req.Slug).First(&existingTag).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tag slug already exists"})
+		return
+	}
+
+	tag := models.Tag{
+		Name: req.Name,
+		Slug: req.Slug,
+	}
+
+	if err := db.Create(&tag).Error; err != nil {
+		if !slugConflict(c, db, "tags", tag.Slug, 0) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag"})
+		}
+		return
+	}
+
+	// A new tag has no posts, so its post_count of 0 is accurate
+	c.JSON(http.StatusCreated, tag)
+}
+
+// @Summary Get all tags
+// @Description Get a page of tags with their post counts. The total count is returned in the
+// @Description X-Total-Count header and links to other pages in the Link header.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page (max 100)" default(20)
+// @Param cursor query string false "Cursor from a previous Link header; empty to start cursor paging"
+// @Param sort query string false "name, created or updated; prefix with - for descending" default(name)
+// @Success 200 {array} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tags [get]
+func GetTags(c *gin.Context) {
+	var tags []models.Tag
+	if !paginate(c, tagWithPostCount(), tagListOptions, &tags) {
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// tagListOptions are the sort fields of tag lists
+var tagListOptions = listOptions{
+	table: "tags",
+	sorts: map[string]sortField{
+		"created": contentSorts["created"],
+		"updated": contentSorts["updated"],
+		"name":    {column: "name"},
+	},
+	defaultSort: "name",
+}
+
+// @Summary Get a tag by ID
+// @Description Get a specific tag by its ID
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Tag ID"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tags/{id} [get]
+func GetTag(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	var tag models.Tag
+	err = tagWithPostCount().Where("tags.id = ?", id).First(&tag).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// @Summary Update a tag
+// @Description Update an existing tag
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Tag ID"
+// @Param request body CreateTagRequest true "Tag update details"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tags/{id} [put]
+func UpdateTag(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	var req CreateTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var tag models.Tag
+	if err := db.First(&tag, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	if !authorize(c, policy.ActionUpdate, &tag) {
+		return
+	}
+
+	// Check if slug is already taken by another tag, including tags in the trash
+	var existingTag models.Tag
+	if err := db.Unscoped().Where("slug = ? AND id <> ?", req.Slug, tag.ID).First(&existingTag).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tag slug already exists"})
+		return
+	}
+
+	tag.Name = req.Name
+	tag.Slug = req.Slug
+
+	if err := db.Save(&tag).Error; err != nil {
+		if !slugConflict(c, db, "tags", tag.Slug, tag.ID) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag"})
+		}
+		return
+	}
+
+	if err := tagWithPostCount().Where("tags.id = ?", tag.ID).First(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// @Summary Delete a tag
+// @Description Move a tag to the trash. It disappears from its posts until it is restored.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Tag ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tags/{id} [delete]
+func DeleteTag(c *gin.Context) {
+	id := c.Param("id")
+	var tag models.Tag
+
+	db := database.GetDB()
+	if err := db.First(&tag, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	if !authorize(c, policy.ActionDelete, &tag) {
+		return
+	}
+
+	if err := db.Delete(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
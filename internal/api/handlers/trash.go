@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/policy"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
+)
+
+// @Summary List trashed posts
+// @Description Get a page of deleted posts that have not been purged yet. Users who may only
+// @Description delete their own posts only see their own. The total count is returned in the
+// @Description X-Total-Count header and links to other pages in the Link header.
+// @Tags trash
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page (max 100)" default(20)
+// @Param cursor query string false "Cursor from a previous Link header; empty to start cursor paging"
+// @Param sort query string false "deleted, created or updated; prefix with - for descending" default(-deleted)
+// @Success 200 {array} models.Post
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /trash/posts [get]
+func GetTrashedPosts(c *gin.Context) {
+	query := database.GetDB().Unscoped().Model(&models.Post{}).
+		Preload("User").Preload("Category").Preload("Tags").
+		Where("posts.deleted_at IS NOT NULL")
+
+	subject := currentSubject(c)
+	deleteAll, err := policy.CanDeleteAll(subject, "posts")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	if !deleteAll {
+		query = query.Where("posts.user_id = ?", subject.UserID)
+	}
+
+	var posts []models.Post
+	if !paginate(c, query, trashListOptions("posts"), &posts) {
+		return
+	}
+
+	c.JSON(http.StatusOK, posts)
+}
+
+// @Summary Restore a trashed post
+// @Description Move a deleted post out of the trash with its tags. Tags that are in the trash
+// @Description themselves reappear on the post when they are restored. Posts whose category is
+// @Description in the trash cannot be restored until the category is.
+// @Tags trash
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Post ID"
+// @Success 200 {object} models.Post
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /trash/posts/{id}/restore [post]
+func RestorePost(c *gin.Context) {
+	var post models.Post
+	if !findTrashed(c, &post, "Post") {
+		return
+	}
+
+	if !authorize(c, policy.ActionDelete, &post) {
+		return
+	}
+
+	db := database.GetDB()
+
+	var count int64
+	if err := db.Model(&models.Category{}).Where("id = ?", post.CategoryID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore post"})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":       "The post's category is in the trash; restore it first",
+			"category_id": post.CategoryID,
+		})
+		return
+	}
+
+	if !restoreTrashed(c, &post, "Post") {
+		return
+	}
+
+	if err := db.Preload("User").Preload("Category").Preload("Tags").First(&post, post.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch post"})
+		return
+	}
+
+	c.JSON(http.StatusOK, post)
+}
+
+// @Summary Purge a trashed post
+// @Description Permanently delete a post in the trash together with its revisions and workflow
+// @Description history
+// @Tags trash
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Post ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /trash/posts/{id} [delete]
+func PurgePost(c *gin.Context) {
+	var post models.Post
+	if !findTrashed(c, &post, "Post") {
+		return
+	}
+
+	if !authorize(c, policy.ActionDelete, &post) {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		return service.PurgePosts(tx, []uint{post.ID})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge post"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Post deleted permanently"})
+}
+
+// @Summary List trashed categories
+// @Description Get a page of deleted categories that have not been purged yet. The total count
+// @Description is returned in the X-Total-Count header and links to other pages in the Link
+// @Description header.
+// @Tags trash
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page (max 100)" default(20)
+// @Param cursor query string false "Cursor from a previous Link header; empty to start cursor paging"
+// @Param sort query string false "deleted, created or updated; prefix with - for descending" default(-deleted)
+// @Success 200 {array} models.Category
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /trash/categories [get]
+func GetTrashedCategories(c *gin.Context) {
+	query := database.GetDB().Unscoped().Model(&models.Category{}).
+		Where("categories.deleted_at IS NOT NULL")
+
+	var categories []models.Category
+	if !paginate(c, query, trashListOptions("categories"), &categories) {
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+// @Summary Restore a trashed category
+// @Description Move a deleted category out of the trash. Its posts in the trash can be restored
+// @Description afterwards.
+// @Tags trash
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Category ID"
+// @Success 200 {object} models.Category
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /trash/categories/{id}/restore [post]
+func RestoreCategory(c *gin.Context) {
+	var category models.Category
+	if !findTrashed(c, &category, "Category") {
+		return
+	}
+
+	if !authorize(c, policy.ActionDelete, &category) {
+		return
+	}
+
+	if !restoreTrashed(c, &category, "Category") {
+		return
+	}
+	category.DeletedAt = gorm.DeletedAt{}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// @Summary Purge a trashed category
+// @Description Permanently delete a category in the trash. Categories cannot be purged while
+// @Description posts, including posts in the trash, belong to them.
+// @Tags trash
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Category ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /trash/categories/{id} [delete]
+func PurgeCategory(c *gin.Context) {
+	var category models.Category
+	if !findTrashed(c, &category, "Category") {
+		return
+	}
+
+	if !authorize(c, policy.ActionDelete, &category) {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		return service.PurgeCategory(tx, category.ID)
+	})
+	if errors.Is(err, service.ErrCategoryInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Category still has posts; purge them first"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge category"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted permanently"})
+}
+
+// @Summary List trashed tags
+// @Description Get a page of deleted tags that have not been purged yet. The total count is
+// @Description returned in the X-Total-Count header and links to other pages in the Link header.
+// @Tags trash
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page (max 100)" default(20)
+// @Param cursor query string false "Cursor from a previous Link header; empty to start cursor paging"
+// @Param sort query string false "deleted, created or updated; prefix with - for descending" default(-deleted)
+// @Success 200 {array} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /trash/tags [get]
+func GetTrashedTags(c *gin.Context) {
+	query := database.GetDB().Unscoped().Model(&models.Tag{}).
+		Where("tags.deleted_at IS NOT NULL")
+
+	var tags []models.Tag
+	if !paginate(c, query, trashListOptions("tags"), &tags) {
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// @Summary Restore a trashed tag
+// @Description Move a deleted tag out of the trash. It reappears on the posts it was attached to.
+// @Tags trash
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Tag ID"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /trash/tags/{id}/restore [post]
+func RestoreTag(c *gin.Context) {
+	var tag models.Tag
+	if !findTrashed(c, &tag, "Tag") {
+		return
+	}
+
+	if !authorize(c, policy.ActionDelete, &tag) {
+		return
+	}
+
+	if !restoreTrashed(c, &tag, "Tag") {
+		return
+	}
+
+	if err := tagWithPostCount().Where("tags.id = ?", tag.ID).First(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// @Summary Purge a trashed tag
+// @Description Permanently delete a tag in the trash and detach it from its posts
+// @Tags trash
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Tag ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /trash/tags/{id} [delete]
+func PurgeTag(c *gin.Context) {
+	var tag models.Tag
+	if !findTrashed(c, &tag, "Tag") {
+		return
+	}
+
+	if !authorize(c, policy.ActionDelete, &tag) {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		return service.PurgeTags(tx, []uint{tag.ID})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted permanently"})
+}
+
+// trashListOptions are the sort fields of trash lists
+func trashListOptions(table string) listOptions {
+	return listOptions{
+		table: table,
+		sorts: map[string]sortField{
+			"deleted": {column: "deleted_at", time: true},
+			"created": contentSorts["created"],
+			"updated": contentSorts["updated"],
+		},
+		defaultSort: "-deleted",
+	}
+}
+
+// findTrashed loads the trashed item with the ID in the path into dest. On
+// failure a response is written and false is returned.
+func findTrashed(c *gin.Context, dest interface{}, name string) bool {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + strings.ToLower(name) + " ID"})
+		return false
+	}
+
+	err = database.GetDB().Unscoped().Where("deleted_at IS NOT NULL").First(dest, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": name + " not found in trash"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch " + strings.ToLower(name)})
+		return false
+	}
+	return true
+}
+
+// restoreTrashed moves a trashed item out of the trash. Its links to other
+// items were kept, so they reappear with it. On failure a response is
+// written and false is returned.
+func restoreTrashed(c *gin.Context, value interface{}, name string) bool {
+	result := database.GetDB().Unscoped().Model(value).UpdateColumn("deleted_at", nil)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore " + strings.ToLower(name)})
+		return false
+	}
+	// The item was purged in the meantime
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": name + " not found in trash"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,572 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/auth"
+	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount is the number of recovery codes issued at a time
+const recoveryCodeCount = 10
+
+type EnrollTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+type TwoFactorPolicyRequest struct {
+	Roles []string `json:"roles" binding:"required,dive,required"`
+}
+
+// @Summary Start two-factor enrollment
+// @Description Generate a new TOTP secret for the authenticated user. Two-factor
+// @Description authentication is only enabled once a code is confirmed at /auth/2fa/activate.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body EnrollTwoFactorRequest true "Current password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/2fa/enroll [post]
+func EnrollTwoFactor(c *gin.Context) {
+	var req EnrollTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, currentUserID(c)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := user.ComparePassword(req.Password); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	if err := db.Model(&user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_counter": 0}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": auth.TOTPURI(totpIssuer(), user.Email, secret),
+	})
+}
+
+// @Summary Activate two-factor authentication
+// @Description Confirm enrollment with a code from the authenticator app. Returns one-time
+// @Description recovery codes, which are only shown once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/2fa/activate [post]
+func ActivateTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A code is required"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, currentUserID(c)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor enrollment has not been started"})
+		return
+	}
+
+	// Guessing codes is throttled like guessing passwords
+	if !loginAllowed(c, user.Email) {
+		return
+	}
+
+	counter, ok := auth.ValidateTOTP(user.TOTPSecret, req.Code, time.Now(), user.TOTPLastCounter)
+	if !ok {
+		secondFactorFailed(c, user.Email)
+		return
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"two_factor_enabled": true,
+			"totp_last_counter":  counter,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// @Summary Disable two-factor authentication
+// @Description Disable two-factor authentication for the authenticated user. Requires the
+// @Description password and a TOTP or recovery code. Not allowed when the user's role requires 2FA.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body DisableTwoFactorRequest true "Password and second factor"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/2fa/disable [post]
+func DisableTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, currentUserID(c)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	required, err := service.RoleRequiresTwoFactor(user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor policy"})
+		return
+	}
+	if required {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+		return
+	}
+
+	if !loginAllowed(c, user.Email) {
+		return
+	}
+
+	if err := user.ComparePassword(req.Password); err != nil {
+		loginFailed(c, user.Email)
+		return
+	}
+
+	if ok, err := verifySecondFactor(db, &user, req.Code, req.RecoveryCode); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	} else if !ok {
+		secondFactorFailed(c, user.Email)
+		return
+	}
+
+	if err := disableTwoFactor(db, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes of the authenticated user. Requires a TOTP code.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/2fa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A code is required"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, currentUserID(c)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if !loginAllowed(c, user.Email) {
+		return
+	}
+
+	if ok, err := verifySecondFactor(db, &user, req.Code, ""); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	} else if !ok {
+		secondFactorFailed(c, user.Email)
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(db, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// @Summary Complete two-factor login
+// @Description Exchange the challenge token from /auth/login and a TOTP or recovery code for a token pair
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorLoginRequest true "Challenge token and second factor"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/2fa/login [post]
+func TwoFactorLogin(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := auth.ValidateChallengeToken(req.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+		return
+	}
+
+	// A used challenge, or one issued before a password change or a logout
+	// everywhere, cannot be redeemed. Inactive users are answered below.
+	if _, err := service.ValidateClaims(claims); err != nil && !errors.Is(err, service.ErrUserInactive) {
+		if errors.Is(err, service.ErrTokenRevoked) || errors.Is(err, service.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate challenge token"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, claims.UserID).Error; err != nil || !user.TwoFactorEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+		return
+	}
+
+	if !user.IsActive() {
+		c.JSON(http.StatusForbidden, suspendedResponse(&user))
+		return
+	}
+
+	// Guessing codes is throttled like guessing passwords
+	if !loginAllowed(c, user.Email) {
+		return
+	}
+
+	if ok, err := verifySecondFactor(db, &user, req.Code, req.RecoveryCode); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	} else if !ok {
+		secondFactorFailed(c, user.Email)
+		return
+	}
+
+	// The challenge is single-use
+	if err := service.RevokeToken(db, claims.ID, user.ID, claims.ExpiresAt.Time); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete login"})
+		return
+	}
+
+	if err := service.RecordLoginSuccess(user.Email); err != nil {
+		log.Printf("Failed to reset login attempts: %v", err)
+	}
+
+	respondWithTokens(c, http.StatusOK, &user)
+}
+
+// @Summary Reset a user's two-factor authentication
+// @Description Disable two-factor authentication for a user who lost their device
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/2fa [delete]
+func ResetUserTwoFactor(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := disableTwoFactor(tx, user.ID); err != nil {
+			return err
+		}
+		return service.InvalidateUserTokens(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+// @Summary Get the two-factor policy
+// @Description Get the roles that are required to use two-factor authentication
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/two-factor-policy [get]
+func GetTwoFactorPolicy(c *gin.Context) {
+	roles, err := service.TwoFactorRequiredRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// @Summary Update the two-factor policy
+// @Description Set the roles that are required to use two-factor authentication
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorPolicyRequest true "Roles requiring 2FA"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/two-factor-policy [put]
+func UpdateTwoFactorPolicy(c *gin.Context) {
+	var req TwoFactorPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, role := range req.Roles {
+		exists, err := service.RoleExists(role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check role"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + role})
+			return
+		}
+	}
+
+	if err := service.SetTwoFactorRequiredRoles(database.GetDB(), req.Roles); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update two-factor policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": req.Roles})
+}
+
+// secondFactorFailed records a failed code like a failed login and writes a
+// 401 response
+func secondFactorFailed(c *gin.Context, email string) {
+	if err := service.RecordLoginFailure(email, c.ClientIP()); err != nil {
+		log.Printf("Failed to record failed login: %v", err)
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+}
+
+// verifySecondFactor checks a TOTP code or, if no code is given, a recovery
+// code. Both are consumed on success so they cannot be replayed.
+func verifySecondFactor(db *gorm.DB, user *models.User, code, recoveryCode string) (bool, error) {
+	switch {
+	case code != "":
+		counter, ok := auth.ValidateTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastCounter)
+		if !ok {
+			return false, nil
+		}
+
+		// Losing this race means the same code was just used elsewhere
+		result := db.Model(&models.User{}).
+			Where("id = ? AND totp_last_counter < ?", user.ID, counter).
+			Update("totp_last_counter", counter)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		user.TOTPLastCounter = counter
+		return result.RowsAffected == 1, nil
+
+	case recoveryCode != "":
+		hash := auth.HashToken(auth.NormalizeRecoveryCode(recoveryCode))
+		result := db.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hash).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return false, result.Error
+		}
+		return result.RowsAffected == 1, nil
+	}
+
+	return false, nil
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and stores a new set,
+// returning the plain codes
+func replaceRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		records := make([]models.RecoveryCode, len(codes))
+		for i, code := range codes {
+			records[i] = models.RecoveryCode{
+				UserID:   userID,
+				CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+			}
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// disableTwoFactor turns off two-factor authentication and removes the
+// user's secret and recovery codes
+func disableTwoFactor(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"two_factor_enabled": false,
+			"totp_secret":        "",
+			"totp_last_counter":  0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// totpIssuer returns the issuer name shown in authenticator apps
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "GoRecta"
+}
@@ -0,0 +1,545 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
+)
+
+type SuspendUserRequest struct {
+	Reason string     `json:"reason" binding:"required"`
+	Until  *time.Time `json:"until"`
+}
+
+type UpdateUserRequest struct {
+	Name            *string `json:"name" binding:"omitempty,min=1"`
+	Email           *string `json:"email" binding:"omitempty,email"`
+	Password        *string `json:"password" binding:"omitempty"`
+	CurrentPassword string  `json:"current_password"`
+	Role            *string `json:"role" binding:"omitempty,min=1"`
+	Active          *bool   `json:"active"`
+}
+
+// @Summary Get all users
+// @Description Get a list of all users, optionally filtered by role and active state
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param role query string false "Filter by role"
+// @Param active query bool false "Filter by active state"
+// @Success 200 {array} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users [get]
+func GetUsers(c *gin.Context) {
+	var users []models.User
+	query := database.GetDB().Order("id")
+
+	// Apply filters
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	if active := c.Query("active"); active != "" {
+		isActive, err := strconv.ParseBool(active)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid active filter"})
+			return
+		}
+		query = query.Where("active = ?", isActive)
+	}
+
+	if err := query.Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// @Summary Get the current user
+// @Description Get the profile of the authenticated user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.User
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/me [get]
+func GetCurrentUser(c *gin.Context) {
+	var user models.User
+	if err := database.GetDB().First(&user, currentUserID(c)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary Get a user by ID
+// @Description Get a specific user by ID. Non-admin users can only view themselves.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id} [get]
+func GetUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	allowed, err := hasPermission(c, "users.read")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	if !allowed && uint(id) != currentUserID(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	var user models.User
+	if err := database.GetDB().First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary Update the current user
+// @Description Update the profile of the authenticated user. Changing the email or password requires
+// @Description current_password and signs the user out everywhere.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UpdateUserRequest true "User update details"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me [put]
+func UpdateCurrentUser(c *gin.Context) {
+	updateUser(c, currentUserID(c))
+}
+
+// @Summary Update a user
+// @Description Update an existing user. Non-admin users can only update their own
+// @Description profile and cannot change their role or active state.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body UpdateUserRequest true "User update details"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id} [put]
+func UpdateUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	updateUser(c, uint(id))
+}
+
+// updateUser applies an UpdateUserRequest to the given user, enforcing the
+// self-service rules for non-admin callers
+func updateUser(c *gin.Context, id uint) {
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Credentials stay with their owner even when an admin acts as them
+	if _, impersonating := c.Get("impersonator_id"); impersonating && (req.Password != nil || req.Email != nil) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed while impersonating"})
+		return
+	}
+
+	admin, err := hasPermission(c, "users.manage")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	self := id == currentUserID(c)
+
+	if !admin && !self {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	// Plain users cannot escalate their role or reactivate themselves
+	if !admin && (req.Role != nil || req.Active != nil) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can change role or active state"})
+		return
+	}
+
+	if req.Role != nil {
+		exists, err := service.RoleExists(*req.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check role"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + *req.Role})
+			return
+		}
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	emailChanged := req.Email != nil && *req.Email != user.Email
+
+	// Users changing their own email or password must confirm the current
+	// password, so that a stolen token cannot take over the account
+	if self && (emailChanged || req.Password != nil) {
+		if err := user.ComparePassword(req.CurrentPassword); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Current password is incorrect"})
+			return
+		}
+	}
+
+	if emailChanged {
+		var existingUser models.User
+		if err := db.Where("email = ? AND id <> ?", *req.Email, user.ID).First(&existingUser).Error; err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+			return
+		}
+		user.Email = *req.Email
+		user.EmailVerified = false
+		user.EmailVerifiedAt = nil
+	}
+
+	if req.Name != nil {
+		user.Name = *req.Name
+	}
+
+	// Changing credentials or privileges invalidates existing sessions
+	invalidateTokens := req.Password != nil || emailChanged ||
+		(req.Role != nil && *req.Role != user.Role) ||
+		(req.Active != nil && *req.Active != user.Active)
+
+	if req.Password != nil {
+		if !checkPasswordPolicy(c, *req.Password, user.Email, user.Name) {
+			return
+		}
+		if err := user.UpdatePassword(*req.Password); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+			return
+		}
+	}
+
+	if req.Role != nil {
+		user.Role = *req.Role
+	}
+
+	if req.Active != nil && *req.Active != user.Active {
+		if *req.Active {
+			user.Reactivate()
+		} else {
+			user.Suspend("Deactivated by an administrator", nil)
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if invalidateTokens {
+			return service.InvalidateUserTokens(tx, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	// A changed email address has to be verified again
+	if emailChanged {
+		if err := sendEmailVerification(db, &user); err != nil {
+			log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary Delete a user
+// @Description Delete a user by ID. If the user has posts, reassign_to must name
+// @Description another user who will take ownership of them; otherwise the delete is blocked.
+// @Description The user's sessions, API keys and linked sign-in identities are deleted with them.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param reassign_to query int false "User ID that receives the deleted user's posts"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id} [delete]
+func DeleteUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if uint(id) == currentUserID(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot delete your own account"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var postCount int64
+	// Posts in the trash are counted and reassigned as well
+	if err := db.Unscoped().Model(&models.Post{}).Where("user_id = ?", user.ID).Count(&postCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count user posts"})
+		return
+	}
+
+	var newOwner models.User
+	if postCount > 0 {
+		reassignTo := c.Query("reassign_to")
+		if reassignTo == "" {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":      "User has posts; provide reassign_to to transfer them",
+				"post_count": postCount,
+			})
+			return
+		}
+
+		newOwnerID, err := strconv.ParseUint(reassignTo, 10, 32)
+		if err != nil || uint(newOwnerID) == user.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reassign_to user ID"})
+			return
+		}
+
+		if err := db.First(&newOwner, newOwnerID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reassignment target not found"})
+			return
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if postCount > 0 {
+			if err := tx.Unscoped().Model(&models.Post{}).Where("user_id = ?", user.ID).Update("user_id", newOwner.ID).Error; err != nil {
+				return err
+			}
+		}
+		// Credentials and sign-in links of the user go with the account
+		for _, model := range []interface{}{
+			&models.UserIdentity{},
+			&models.APIKey{},
+			&models.Session{},
+			&models.RefreshToken{},
+			&models.RecoveryCode{},
+			&models.PasswordResetToken{},
+			&models.EmailVerificationToken{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&user).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// @Summary Suspend a user
+// @Description Suspend a user with a reason and an optional expiry. The user's existing tokens stop working immediately.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body SuspendUserRequest true "Suspension details"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/suspend [post]
+func SuspendUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req SuspendUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Until != nil && !req.Until.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Suspension expiry must be in the future"})
+		return
+	}
+
+	if uint(id) == currentUserID(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot suspend your own account"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	user.Suspend(req.Reason, req.Until)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return service.InvalidateUserTokens(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suspend user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary Reactivate a user
+// @Description Lift a user's suspension
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/reactivate [post]
+func ReactivateUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	user.Reactivate()
+
+	if err := db.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary Unlock a user
+// @Description Clear failed login attempts and lift a login lockout of a user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/unlock [post]
+func UnlockUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := service.UnlockAccount(db, user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	actorID := currentUserID(c)
+	service.RecordAudit(db, "login.account_unlocked", &actorID, &user.ID, c.ClientIP(), nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// currentUserID returns the ID of the authenticated user set by AuthMiddleware
+func currentUserID(c *gin.Context) uint {
+	userID, _ := c.Get("user_id")
+	id, _ := userID.(uint)
+	return id
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/pkg/auth"
+	"github.com/truncgil/gorecta/pkg/database"
+	"github.com/truncgil/gorecta/pkg/mailer"
+	"gorm.io/gorm"
+)
+
+type VerifyEmailRequest struct {
+	Token string `form:"token" json:"token" binding:"required"`
+}
+
+// @Summary Verify email address
+// @Description Confirm a user's email address with the token from a verification email
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token query string false "Verification token (GET)"
+// @Param request body VerifyEmailRequest false "Verification token (POST)"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/verify-email [post]
+func VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var verification models.EmailVerificationToken
+	if err := db.Where("token_hash = ?", auth.HashToken(req.Token)).First(&verification).Error; err != nil ||
+		verification.UsedAt != nil || time.Now().After(verification.ExpiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.EmailVerificationToken{}).
+			Where("id = ? AND used_at IS NULL", verification.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVerificationTokenInvalid
+		}
+
+		// The token only verifies the address it was sent to
+		result = tx.Model(&models.User{}).
+			Where("id = ? AND email = ?", verification.UserID, verification.Email).
+			Updates(map[string]interface{}{"email_verified": true, "email_verified_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVerificationTokenInvalid
+		}
+		return nil
+	})
+	if errors.Is(err, errVerificationTokenInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// @Summary Resend verification email
+// @Description Send a new verification email to the authenticated user. Requests are throttled.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/resend-verification [post]
+func ResendVerification(c *gin.Context) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, currentUserID(c)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.EmailVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified"})
+		return
+	}
+
+	// Throttle based on when the last verification email was sent
+	var last models.EmailVerificationToken
+	if err := db.Where("user_id = ?", user.ID).Order("created_at DESC").First(&last).Error; err == nil {
+		if wait := time.Until(last.CreatedAt.Add(verificationResendInterval())); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait before requesting another verification email"})
+			return
+		}
+	}
+
+	if err := sendEmailVerification(db, &user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+var errVerificationTokenInvalid = errors.New("verification token invalid")
+
+// sendEmailVerification issues a verification token for the user's current
+// email address and mails them a verification link
+func sendEmailVerification(db *gorm.DB, user *models.User) error {
+	token, tokenHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Only the most recently sent link stays valid
+		if err := tx.Model(&models.EmailVerificationToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.EmailVerificationToken{
+			UserID:    user.ID,
+			Email:     user.Email,
+			TokenHash: tokenHash,
+			ExpiresAt: time.Now().Add(emailVerificationTTL()),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", os.Getenv("APP_URL"), url.QueryEscape(token))
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s",
+			user.Name, emailVerificationTTL(), link),
+	}
+
+	// Deliver in the background so slow mail servers do not block the request
+	go func() {
+		if err := mailer.GetMailer().Send(msg); err != nil {
+			log.Printf("Failed to send verification email: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// emailVerificationTTL returns how long email verification links stay valid
+func emailVerificationTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_EXPIRATION"))
+	if err != nil || ttl <= 0 {
+		return 48 * time.Hour
+	}
+	return ttl
+}
+
+// verificationResendInterval returns the minimum time between verification emails
+func verificationResendInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_RESEND_INTERVAL"))
+	if err != nil || interval < 0 {
+		return time.Minute
+	}
+	return interval
+}
@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/policy"
+	"github.com/truncgil/gorecta/internal/service"
+	"github.com/truncgil/gorecta/pkg/database"
+	"github.com/truncgil/gorecta/pkg/mailer"
+	"gorm.io/gorm"
+)
+
+var (
+	errReviewerNotAllowed = errors.New("reviewer may not review posts")
+	errPostStatusChanged  = errors.New("post status changed")
+)
+
+type TransitionPostRequest struct {
+	Transition string `json:"transition" binding:"required"`
+	Comment    string `json:"comment"`
+	// PublishAt schedules the post when publishing; defaults to now
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+	// ReviewerID assigns a reviewer along with the transition; requires
+	// posts.review
+	ReviewerID *uint `json:"reviewer_id"`
+}
+
+type AssignReviewerRequest struct {
+	// ReviewerID is the reviewer to assign, or null to unassign
+	ReviewerID *uint `json:"reviewer_id"`
+}
+
+type WorkflowRequest struct {
+	Transitions []service.WorkflowTransition `json:"transitions" binding:"required"`
+}
+
+// @Summary Get post workflow state
+// @Description Get the status of a post, the transitions the authenticated user can make and
+// @Description the transition history
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Post ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/{id}/transitions [get]
+func GetPostTransitions(c *gin.Context) {
+	post, ok := workflowPost(c)
+	if !ok {
+		return
+	}
+
+	workflow, err := service.Workflow()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load workflow"})
+		return
+	}
+
+	available := []string{}
+	subject := currentSubject(c)
+	for _, t := range workflow {
+		if t.Allows(post.Status) && policy.Authorize(subject, policy.Action(t.Action), post) == nil {
+			available = append(available, t.Name)
+		}
+	}
+
+	var history []models.PostTransition
+	if err := database.GetDB().Preload("User").
+		Where("post_id = ?", post.ID).
+		Order("created_at DESC, id DESC").
+		Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transitions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      post.Status,
+		"reviewer_id": post.ReviewerID,
+		"available":   available,
+		"history":     history,
+	})
+}
+
+// @Summary Transition a post
+// @Description Move a post through the editorial workflow, e.g. submit, approve, send_back or
+// @Description publish. Publishing with a future publish_at schedules the post. Transitions that
+// @Description notify the author email them the comment. Assigning a reviewer_id requires posts.review.
+// @Tags posts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Post ID"
+// @Param request body TransitionPostRequest true "Transition details"
+// @Success 200 {object} models.Post
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/{id}/transitions [post]
+func TransitionPost(c *gin.Context) {
+	var req TransitionPostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	post, ok := workflowPost(c)
+	if !ok {
+		return
+	}
+
+	workflow, err := service.Workflow()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load workflow"})
+		return
+	}
+	transition, ok := service.FindTransition(workflow, req.Transition)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown transition: " + req.Transition})
+		return
+	}
+	if !transition.Allows(post.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot %s a post that is %s", transition.Name, post.Status)})
+		return
+	}
+	if !authorize(c, policy.Action(transition.Action), post) {
+		return
+	}
+	if req.ReviewerID != nil {
+		allowed, err := hasPermission(c, "posts.review")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to assign a reviewer"})
+			return
+		}
+	}
+
+	comment := strings.TrimSpace(req.Comment)
+	if transition.CommentRequired && comment == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A comment is required to " + transition.Name + " a post"})
+		return
+	}
+
+	fromStatus := post.Status
+	if err := setPostStatus(post, transition.To, req.PublishAt, req.UnpublishAt, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	userID := currentUserID(c)
+
+	if req.ReviewerID != nil {
+		if !checkReviewer(c, db, *req.ReviewerID) {
+			return
+		}
+		post.ReviewerID = req.ReviewerID
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := service.EnsureBaseRevision(tx, post.ID); err != nil {
+			return err
+		}
+
+		// Losing this race means another transition was made meanwhile
+		result := tx.Model(post).Where("status = ?", fromStatus).
+			Select("status", "published", "publish_at", "unpublish_at", "reviewer_id").
+			Updates(post)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errPostStatusChanged
+		}
+
+		if err := tx.Create(&models.PostTransition{
+			PostID:     post.ID,
+			UserID:     &userID,
+			Transition: transition.Name,
+			FromStatus: fromStatus,
+			ToStatus:   post.Status,
+			Comment:    comment,
+		}).Error; err != nil {
+			return err
+		}
+
+		_, err := service.RecordPostRevision(tx, post.ID, userID, nil)
+		return err
+	})
+	if errors.Is(err, errPostStatusChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The post status changed meanwhile; reload it and try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transition post"})
+		return
+	}
+
+	if err := db.Preload("User").Preload("Category").Preload("Tags").Preload("Reviewer").First(post, post.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch post"})
+		return
+	}
+
+	if transition.NotifyAuthor && post.UserID != userID {
+		var actor models.User
+		db.Select("name").First(&actor, userID)
+		go sendTransitionNotice(*post, fromStatus, actor.Name, comment)
+	}
+	if req.ReviewerID != nil && *req.ReviewerID != userID {
+		go sendReviewRequest(*post, *req.ReviewerID)
+	}
+
+	c.JSON(http.StatusOK, post)
+}
+
+// @Summary Assign a post reviewer
+// @Description Assign a user who may review posts to review the post, or unassign the reviewer
+// @Tags posts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Post ID"
+// @Param request body AssignReviewerRequest true "Reviewer"
+// @Success 200 {object} models.Post
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/{id}/reviewer [put]
+func AssignPostReviewer(c *gin.Context) {
+	var req AssignReviewerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	post, ok := workflowPost(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	if req.ReviewerID != nil && !checkReviewer(c, db, *req.ReviewerID) {
+		return
+	}
+
+	if err := db.Model(post).Update("reviewer_id", req.ReviewerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign reviewer"})
+		return
+	}
+
+	if err := db.Preload("User").Preload("Category").Preload("Tags").Preload("Reviewer").First(post, post.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch post"})
+		return
+	}
+
+	if req.ReviewerID != nil && *req.ReviewerID != currentUserID(c) {
+		go sendReviewRequest(*post, *req.ReviewerID)
+	}
+
+	c.JSON(http.StatusOK, post)
+}
+
+// @Summary Get the post workflow
+// @Description Get the transitions posts can go through
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/workflow [get]
+func GetWorkflow(c *gin.Context) {
+	workflow, err := service.Workflow()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load workflow"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"statuses": models.PostStatuses, "transitions": workflow})
+}
+
+// @Summary Update the post workflow
+// @Description Replace the transitions posts can go through. Each transition names the statuses
+// @Description it starts from, the status it leads to and the action whose posts.<action> (or
+// @Description posts.<action>_own for authors) permission it requires.
+// @Tags posts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body WorkflowRequest true "Workflow transitions"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/workflow [put]
+func UpdateWorkflow(c *gin.Context) {
+	var req WorkflowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := service.SetWorkflow(database.GetDB(), req.Transitions)
+	if errors.Is(err, service.ErrInvalidWorkflow) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update workflow"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"statuses": models.PostStatuses, "transitions": req.Transitions})
+}
+
+// setPostStatus moves the post to the status. Publishing with a future
+// publishAt schedules the post instead; unpublishAt archives it later.
+func setPostStatus(post *models.Post, status string, publishAt, unpublishAt *time.Time, now time.Time) error {
+	if status == models.PostStatusPublished && publishAt != nil && publishAt.After(now) {
+		status = models.PostStatusScheduled
+	}
+
+	switch status {
+	case models.PostStatusScheduled:
+		if publishAt == nil || !publishAt.After(now) {
+			return errors.New("publish_at must be in the future to schedule a post")
+		}
+		post.PublishAt = publishAt
+	case models.PostStatusPublished:
+		if publishAt != nil {
+			post.PublishAt = publishAt
+		} else {
+			post.PublishAt = &now
+		}
+	case models.PostStatusArchived:
+		// Keep when the post went live
+	default:
+		post.PublishAt = nil
+		post.UnpublishAt = nil
+	}
+
+	if status == models.PostStatusScheduled || status == models.PostStatusPublished {
+		live := now
+		if status == models.PostStatusScheduled {
+			live = *post.PublishAt
+		}
+		if unpublishAt != nil && !unpublishAt.After(live) {
+			return errors.New("unpublish_at must be after the post goes live")
+		}
+		post.UnpublishAt = unpublishAt
+	}
+
+	post.Status = status
+	post.Published = status == models.PostStatusPublished
+	return nil
+}
+
+// workflowPost loads the post of a workflow request, hiding posts the user
+// may not read. On failure a response is written and false is returned.
+func workflowPost(c *gin.Context) (*models.Post, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return nil, false
+	}
+
+	var post models.Post
+	if err := database.GetDB().First(&post, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+
+	if err := policy.Authorize(currentSubject(c), policy.ActionRead, &post); err != nil {
+		if errors.Is(err, policy.ErrForbidden) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		}
+		return nil, false
+	}
+	return &post, true
+}
+
+// checkReviewer verifies that the user exists, is active and may review
+// posts. On failure a response is written and false is returned.
+func checkReviewer(c *gin.Context, db *gorm.DB, reviewerID uint) bool {
+	err := func() error {
+		var reviewer models.User
+		if err := db.First(&reviewer, reviewerID).Error; err != nil {
+			return err
+		}
+		allowed, err := service.RoleHasPermission(reviewer.Role, "posts.review")
+		if err != nil {
+			return err
+		}
+		if !allowed || !reviewer.Active {
+			return errReviewerNotAllowed
+		}
+		return nil
+	}()
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, errReviewerNotAllowed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reviewer must be an active user who may review posts"})
+		return false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check reviewer"})
+		return false
+	}
+	return true
+}
+
+// sendTransitionNotice tells the author of a post that it was moved, e.g.
+// sent back to draft, along with the reviewer's comment
+func sendTransitionNotice(post models.Post, fromStatus, actorName, comment string) {
+	body := fmt.Sprintf("Hello %s,\n\n%s moved your post \"%s\" from %s to %s.\n\n",
+		post.User.Name, actorName, post.Title, statusLabel(fromStatus), statusLabel(post.Status))
+	if comment != "" {
+		body += fmt.Sprintf("Comment:\n%s\n\n", comment)
+	}
+	body += fmt.Sprintf("%s/posts/%d", os.Getenv("APP_URL"), post.ID)
+
+	msg := mailer.Message{
+		To:      post.User.Email,
+		Subject: fmt.Sprintf("Your post \"%s\" is now %s", post.Title, statusLabel(post.Status)),
+		Body:    body,
+	}
+	if err := mailer.GetMailer().Send(msg); err != nil {
+		log.Printf("Failed to send post transition email: %v", err)
+	}
+}
+
+// sendReviewRequest tells a user that they were assigned to review a post
+func sendReviewRequest(post models.Post, reviewerID uint) {
+	var reviewer models.User
+	if err := database.GetDB().First(&reviewer, reviewerID).Error; err != nil {
+		log.Printf("Failed to load reviewer: %v", err)
+		return
+	}
+
+	msg := mailer.Message{
+		To:      reviewer.Email,
+		Subject: fmt.Sprintf("Please review \"%s\"", post.Title),
+		Body: fmt.Sprintf("Hello %s,\n\nYou have been assigned to review the post \"%s\" by %s.\n\n%s/posts/%d",
+			reviewer.Name, post.Title, post.User.Name, os.Getenv("APP_URL"), post.ID),
+	}
+	if err := mailer.GetMailer().Send(msg); err != nil {
+		log.Printf("Failed to send review request email: %v", err)
+	}
+}
+
+// statusLabel returns a post status as written in prose
+func statusLabel(status string) string {
+	return strings.ReplaceAll(status, "_", " ")
+}
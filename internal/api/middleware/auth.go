@@ -1,14 +1,19 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/internal/service"
 	"github.com/truncgil/gorecta/pkg/auth"
+	"github.com/truncgil/gorecta/pkg/database"
 )
 
-// AuthMiddleware verifies the JWT token in the Authorization header
+// AuthMiddleware verifies the JWT token or API key in the Authorization header
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -26,6 +31,12 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// API keys are sent as bearer credentials too
+		if auth.IsAPIKey(parts[1]) {
+			authenticateAPIKey(c, parts[1])
+			return
+		}
+
 		// Validate the token
 		claims, err := auth.ValidateToken(parts[1])
 		if err != nil {
@@ -34,35 +45,226 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Check the token against revocations and the user's token cut-off
+		user, err := service.ValidateClaims(claims)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrUserInactive):
+				c.JSON(http.StatusForbidden, gin.H{"error": "Account is suspended"})
+			case errors.Is(err, service.ErrTokenRevoked), errors.Is(err, service.ErrUserNotFound):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+			}
+			c.Abort()
+			return
+		}
+
+		// Reject tokens of revoked sessions and record the session's activity
+		if err := service.ValidateSession(claims, c.ClientIP(), c.Request.UserAgent()); err != nil {
+			if errors.Is(err, service.ErrTokenRevoked) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+			}
+			c.Abort()
+			return
+		}
+
+		// Impersonation ends as soon as the admin may no longer impersonate
+		if claims.IsImpersonation() {
+			if err := service.ValidateImpersonator(claims.ActorID); err != nil {
+				if errors.Is(err, service.ErrTokenRevoked) {
+					c.JSON(http.StatusUnauthorized, gin.H{"error": "Impersonation has ended"})
+				} else {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+				}
+				c.Abort()
+				return
+			}
+			c.Set("impersonator_id", claims.ActorID)
+		}
+
 		// Set user information in the context
+		c.Set("claims", claims)
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
 		c.Set("role", claims.Role)
+		c.Set("session_id", claims.SessionID)
+		c.Set("email_verified", user.EmailVerified)
+		c.Set("two_factor_enabled", user.TwoFactorEnabled)
+
+		c.Next()
+
+		// Every request made while impersonating is audited
+		if claims.IsImpersonation() {
+			actorID, userID := claims.ActorID, claims.UserID
+			service.RecordAudit(database.GetDB(), "impersonation.request", &actorID, &userID, c.ClientIP(), map[string]interface{}{
+				"method": c.Request.Method,
+				"path":   c.Request.URL.Path,
+				"status": c.Writer.Status(),
+			})
+		}
+	}
+}
+
+// authenticateAPIKey authenticates the request with an API key, acting as
+// the key's owner
+func authenticateAPIKey(c *gin.Context, key string) {
+	apiKey, user, err := service.AuthenticateAPIKey(key, c.ClientIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserInactive):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is suspended"})
+		case errors.Is(err, service.ErrInvalidAPIKey):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate API key"})
+		}
+		c.Abort()
+		return
+	}
+
+	// Set user information in the context
+	c.Set("api_key", apiKey)
+	c.Set("user_id", user.ID)
+	c.Set("email", user.Email)
+	c.Set("role", user.Role)
+	c.Set("email_verified", user.EmailVerified)
+	c.Set("two_factor_enabled", user.TwoFactorEnabled)
+
+	c.Next()
+}
+
+// ScopeMiddleware restricts API keys to requests covered by their scopes:
+// "<resource>:read" for safe methods and "<resource>:write" otherwise.
+// Requests authenticated with a user token are not affected.
+func ScopeMiddleware(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("api_key")
+		if !exists {
+			c.Next()
+			return
+		}
+
+		scope := resource + ":write"
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			scope = resource + ":read"
+		}
+
+		if !value.(*models.APIKey).HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing scope " + scope})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// NoImpersonationMiddleware blocks sensitive account actions, such as
+// changing credentials, for requests made with an impersonation token
+func NoImpersonationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("impersonator_id"); exists {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed while impersonating"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// UserTokenMiddleware rejects requests authenticated with an API key, for
+// account and administration routes that machine clients must not reach
+func UserTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("api_key"); exists {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot access this endpoint"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// VerifiedEmailMiddleware blocks users with an unverified email from
+// content-changing requests when REQUIRE_EMAIL_VERIFICATION is enabled
+func VerifiedEmailMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true" && !c.GetBool("email_verified") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address is not verified"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// TwoFactorPolicyMiddleware blocks users whose role requires two-factor
+// authentication until they have enabled it
+func TwoFactorPolicyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("two_factor_enabled") {
+			c.Next()
+			return
+		}
+
+		required, err := service.RoleRequiresTwoFactor(c.GetString("role"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor policy"})
+			c.Abort()
+			return
+		}
+
+		if required {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":                     "Two-factor authentication is required for your role",
+				"two_factor_setup_required": true,
+			})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
 }
 
-// RoleMiddleware checks if the user has the required role
-func RoleMiddleware(roles ...string) gin.HandlerFunc {
+// RequirePermission checks if the user's role grants any of the permissions
+func RequirePermission(permissions ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userRole, exists := c.Get("role")
+		role, exists := c.Get("role")
 		if !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found"})
 			c.Abort()
 			return
 		}
 
-		// Check if the user's role is in the allowed roles
-		roleAllowed := false
-		for _, role := range roles {
-			if role == userRole.(string) {
-				roleAllowed = true
+		allowed := false
+		for _, permission := range permissions {
+			granted, err := service.RoleHasPermission(role.(string), permission)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+				c.Abort()
+				return
+			}
+			if granted {
+				allowed = true
 				break
 			}
 		}
 
-		if !roleAllowed {
+		if !allowed {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
 			c.Abort()
 			return
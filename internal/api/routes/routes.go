@@ -20,6 +20,9 @@ func SetupRoutes(router *gin.Engine) {
 	docs.SwaggerInfo.Schemes = []string{"http", "https"}
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Public keys for verifying tokens
+	router.GET("/.well-known/jwks.json", handlers.JWKS)
+
 	// API v1 group
 	v1 := router.Group("/api/v1")
 
@@ -28,49 +31,180 @@ func SetupRoutes(router *gin.Engine) {
 	{
 		auth.POST("/register", handlers.Register)
 		auth.POST("/login", handlers.Login)
+		auth.POST("/refresh", handlers.Refresh)
+		auth.POST("/forgot-password", handlers.ForgotPassword)
+		auth.POST("/reset-password", handlers.ResetPassword)
+		auth.GET("/verify-email", handlers.VerifyEmail)
+		auth.POST("/verify-email", handlers.VerifyEmail)
+		auth.POST("/2fa/login", handlers.TwoFactorLogin)
+		auth.POST("/accept-invitation", handlers.AcceptInvitation)
+		auth.GET("/oidc/providers", handlers.GetOIDCProviders)
+		auth.GET("/oidc/:provider/login", handlers.OIDCLogin)
+		auth.GET("/oidc/:provider/callback", handlers.OIDCCallback)
+		auth.POST("/oidc/:provider/callback", handlers.OIDCCallback)
 	}
 
 	// Protected routes
 	protected := v1.Group("")
 	protected.Use(middleware.AuthMiddleware())
+
+	// Account routes that API keys cannot reach
+	account := protected.Group("")
+	account.Use(middleware.UserTokenMiddleware())
+	{
+		account.POST("/auth/logout", handlers.Logout)
+		account.POST("/auth/resend-verification", handlers.ResendVerification)
+		account.GET("/auth/sessions", handlers.GetSessions)
+		account.DELETE("/auth/sessions", middleware.NoImpersonationMiddleware(), handlers.RevokeOtherSessions)
+		account.DELETE("/auth/sessions/:id", middleware.NoImpersonationMiddleware(), handlers.RevokeSession)
+
+		// Two-factor authentication routes
+		twoFactor := account.Group("/auth/2fa")
+		twoFactor.Use(middleware.NoImpersonationMiddleware())
+		{
+			twoFactor.POST("/enroll", handlers.EnrollTwoFactor)
+			twoFactor.POST("/activate", handlers.ActivateTwoFactor)
+			twoFactor.POST("/disable", handlers.DisableTwoFactor)
+			twoFactor.POST("/recovery-codes", handlers.RegenerateRecoveryCodes)
+		}
+	}
+
+	// Routes that users whose role requires 2FA can only reach once enabled
+	secured := protected.Group("")
+	secured.Use(middleware.TwoFactorPolicyMiddleware())
 	{
 		// Posts routes
-		posts := protected.Group("/posts")
+		posts := secured.Group("/posts")
+		posts.Use(middleware.ScopeMiddleware("posts"), middleware.VerifiedEmailMiddleware())
 		{
-			posts.GET("", handlers.GetPosts)
-			posts.POST("", middleware.RoleMiddleware("admin", "editor"), handlers.CreatePost)
-			posts.GET("/:id", handlers.GetPost)
-			posts.PUT("/:id", middleware.RoleMiddleware("admin", "editor"), handlers.UpdatePost)
-			posts.DELETE("/:id", middleware.RoleMiddleware("admin"), handlers.DeletePost)
+			posts.GET("", middleware.RequirePermission("posts.read"), handlers.GetPosts)
+			posts.GET("/search", middleware.RequirePermission("posts.read"), handlers.SearchPosts)
+			posts.GET("/slug/:slug", middleware.RequirePermission("posts.read"), handlers.ResolvePostSlug)
+			posts.POST("", middleware.RequirePermission("posts.create"), handlers.CreatePost)
+			posts.GET("/:id", middleware.RequirePermission("posts.read"), handlers.GetPost)
+			posts.PUT("/:id", middleware.RequirePermission("posts.update", "posts.update_own"), handlers.UpdatePost)
+			posts.DELETE("/:id", middleware.RequirePermission("posts.delete", "posts.delete_own"), handlers.DeletePost)
+			posts.GET("/:id/revisions", middleware.RequirePermission("posts.update", "posts.update_own"), handlers.GetPostRevisions)
+			posts.GET("/:id/revisions/diff", middleware.RequirePermission("posts.update", "posts.update_own"), handlers.DiffPostRevisions)
+			posts.GET("/:id/revisions/:number", middleware.RequirePermission("posts.update", "posts.update_own"), handlers.GetPostRevision)
+			posts.POST("/:id/revisions/:number/restore", middleware.RequirePermission("posts.update", "posts.update_own"), handlers.RestorePostRevision)
+			posts.GET("/:id/transitions", middleware.RequirePermission("posts.read"), handlers.GetPostTransitions)
+			posts.POST("/:id/transitions", middleware.RequirePermission("posts.read"), handlers.TransitionPost)
+			posts.PUT("/:id/reviewer", middleware.RequirePermission("posts.review"), handlers.AssignPostReviewer)
 		}
 
 		// Categories routes
-		categories := protected.Group("/categories")
+		categories := secured.Group("/categories")
+		categories.Use(middleware.ScopeMiddleware("categories"), middleware.VerifiedEmailMiddleware())
+		{
+			categories.GET("", middleware.RequirePermission("categories.read"), handlers.GetCategories)
+			categories.GET("/slug/:slug", middleware.RequirePermission("categories.read"), handlers.ResolveCategorySlug)
+			categories.POST("", middleware.RequirePermission("categories.create"), handlers.CreateCategory)
+			categories.GET("/:id", middleware.RequirePermission("categories.read"), handlers.GetCategory)
+			categories.PUT("/:id", middleware.RequirePermission("categories.update"), handlers.UpdateCategory)
+			categories.DELETE("/:id", middleware.RequirePermission("categories.delete"), handlers.DeleteCategory)
+		}
+
+		// Tags routes
+		tags := secured.Group("/tags")
+		tags.Use(middleware.ScopeMiddleware("tags"), middleware.VerifiedEmailMiddleware())
+		{
+			tags.GET("", middleware.RequirePermission("tags.read"), handlers.GetTags)
+			tags.POST("", middleware.RequirePermission("tags.create"), handlers.CreateTag)
+			tags.GET("/:id", middleware.RequirePermission("tags.read"), handlers.GetTag)
+			tags.PUT("/:id", middleware.RequirePermission("tags.update"), handlers.UpdateTag)
+			tags.DELETE("/:id", middleware.RequirePermission("tags.delete"), handlers.DeleteTag)
+		}
+
+		// Trash routes
+		trash := secured.Group("/trash")
+		trash.Use(middleware.VerifiedEmailMiddleware())
+		{
+			trashedPosts := trash.Group("/posts")
+			trashedPosts.Use(middleware.ScopeMiddleware("posts"), middleware.RequirePermission("posts.delete", "posts.delete_own"))
+			{
+				trashedPosts.GET("", handlers.GetTrashedPosts)
+				trashedPosts.POST("/:id/restore", handlers.RestorePost)
+				trashedPosts.DELETE("/:id", handlers.PurgePost)
+			}
+
+			trashedCategories := trash.Group("/categories")
+			trashedCategories.Use(middleware.ScopeMiddleware("categories"), middleware.RequirePermission("categories.delete"))
+			{
+				trashedCategories.GET("", handlers.GetTrashedCategories)
+				trashedCategories.POST("/:id/restore", handlers.RestoreCategory)
+				trashedCategories.DELETE("/:id", handlers.PurgeCategory)
+			}
+
+			trashedTags := trash.Group("/tags")
+			trashedTags.Use(middleware.ScopeMiddleware("tags"), middleware.RequirePermission("tags.delete"))
+			{
+				trashedTags.GET("", handlers.GetTrashedTags)
+				trashedTags.POST("/:id/restore", handlers.RestoreTag)
+				trashedTags.DELETE("/:id", handlers.PurgeTag)
+			}
+		}
+
+		// Users routes
+		users := secured.Group("/users")
+		users.Use(middleware.ScopeMiddleware("users"))
+		{
+			users.GET("", middleware.RequirePermission("users.read"), handlers.GetUsers)
+			users.GET("/me", handlers.GetCurrentUser)
+			users.PUT("/me", handlers.UpdateCurrentUser)
+			users.GET("/:id", handlers.GetUser)
+			users.PUT("/:id", handlers.UpdateUser)
+			users.DELETE("/:id", middleware.RequirePermission("users.manage"), handlers.DeleteUser)
+			users.POST("/:id/suspend", middleware.RequirePermission("users.manage"), handlers.SuspendUser)
+			users.POST("/:id/reactivate", middleware.RequirePermission("users.manage"), handlers.ReactivateUser)
+			users.POST("/:id/unlock", middleware.RequirePermission("users.manage"), handlers.UnlockUser)
+			users.DELETE("/:id/2fa", middleware.RequirePermission("users.manage"), handlers.ResetUserTwoFactor)
+			users.POST("/:id/impersonate", middleware.UserTokenMiddleware(), middleware.NoImpersonationMiddleware(), middleware.RequirePermission("users.impersonate"), handlers.ImpersonateUser)
+			users.GET("/:id/sessions", middleware.UserTokenMiddleware(), middleware.RequirePermission("users.manage"), handlers.GetUserSessions)
+			users.DELETE("/:id/sessions", middleware.UserTokenMiddleware(), middleware.RequirePermission("users.manage"), handlers.RevokeUserSessions)
+			users.DELETE("/:id/sessions/:session_id", middleware.UserTokenMiddleware(), middleware.RequirePermission("users.manage"), handlers.RevokeUserSession)
+		}
+
+		// API key routes
+		apiKeys := secured.Group("/api-keys")
+		apiKeys.Use(middleware.UserTokenMiddleware(), middleware.NoImpersonationMiddleware())
+		{
+			apiKeys.GET("", handlers.GetAPIKeys)
+			apiKeys.POST("", handlers.CreateAPIKey)
+			apiKeys.DELETE("/:id", handlers.RevokeAPIKey)
+		}
+
+		// Invitation routes
+		invitations := secured.Group("/invitations")
+		invitations.Use(middleware.UserTokenMiddleware(), middleware.RequirePermission("users.manage"))
 		{
-			categories.GET("", handlers.GetCategories)
-			categories.POST("", middleware.RoleMiddleware("admin"), handlers.CreateCategory)
-			categories.GET("/:id", handlers.GetCategory)
-			categories.PUT("/:id", middleware.RoleMiddleware("admin"), handlers.UpdateCategory)
-			categories.DELETE("/:id", middleware.RoleMiddleware("admin"), handlers.DeleteCategory)
+			invitations.GET("", handlers.GetInvitations)
+			invitations.POST("", handlers.CreateInvitation)
+			invitations.DELETE("/:id", handlers.RevokeInvitation)
 		}
 
-		// Tags routes (to be implemented)
-		tags := protected.Group("/tags")
+		// Roles and permissions routes
+		roles := secured.Group("/roles")
+		roles.Use(middleware.UserTokenMiddleware(), middleware.RequirePermission("roles.manage"))
 		{
-			tags.GET("")
-			tags.POST("", middleware.RoleMiddleware("admin"))
-			tags.GET("/:id")
-			tags.PUT("/:id", middleware.RoleMiddleware("admin"))
-			tags.DELETE("/:id", middleware.RoleMiddleware("admin"))
+			roles.GET("", handlers.GetRoles)
+			roles.POST("", handlers.CreateRole)
+			roles.GET("/:id", handlers.GetRole)
+			roles.PUT("/:id", handlers.UpdateRole)
+			roles.DELETE("/:id", handlers.DeleteRole)
 		}
+		secured.GET("/permissions", middleware.UserTokenMiddleware(), middleware.RequirePermission("roles.manage"), handlers.GetPermissions)
 
-		// Users routes (to be implemented)
-		users := protected.Group("/users")
+		// Admin routes
+		admin := secured.Group("/admin")
+		admin.Use(middleware.UserTokenMiddleware(), middleware.RequirePermission("settings.manage"))
 		{
-			users.GET("", middleware.RoleMiddleware("admin"))
-			users.GET("/:id")
-			users.PUT("/:id")
-			users.DELETE("/:id", middleware.RoleMiddleware("admin"))
+			admin.GET("/two-factor-policy", handlers.GetTwoFactorPolicy)
+			admin.PUT("/two-factor-policy", handlers.UpdateTwoFactorPolicy)
+			admin.GET("/search-settings", handlers.GetSearchSettings)
+			admin.PUT("/search-settings", handlers.UpdateSearchSettings)
+			admin.GET("/workflow", handlers.GetWorkflow)
+			admin.PUT("/workflow", handlers.UpdateWorkflow)
 		}
 	}
 }
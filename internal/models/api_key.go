@@ -0,0 +1,58 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKeyScopes lists the scopes that can be granted to API keys
+var APIKeyScopes = []string{
+	"posts:read", "posts:write",
+	"categories:read", "categories:write",
+	"tags:read", "tags:write",
+	"media:read", "media:write",
+	"users:read", "users:write",
+}
+
+// APIKey is a named, hashed credential for machine clients acting on behalf
+// of its owner, limited to its scopes
+type APIKey struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Name       string     `gorm:"not null" json:"name"`
+	KeyID      string     `gorm:"uniqueIndex;not null" json:"key_id"`
+	KeyHash    string     `gorm:"not null" json:"-"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	Scopes     string     `gorm:"not null" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// ScopeList returns the key's scopes
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// HasScope reports whether the key was granted the scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValid reports whether the key is neither revoked nor expired
+func (k *APIKey) IsValid() bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt)
+}
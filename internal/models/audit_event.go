@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditEvent records a security-relevant action
+type AuditEvent struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+	Action       string    `gorm:"index;not null" json:"action"`
+	ActorID      *uint     `gorm:"index" json:"actor_id"`
+	TargetUserID *uint     `gorm:"index" json:"target_user_id"`
+	IP           string    `json:"ip"`
+	Details      string    `gorm:"type:text" json:"details"`
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Category struct {
@@ -12,4 +14,16 @@ type Category struct {
 	Slug        string    `gorm:"unique;not null" json:"slug"`
 	Description string    `json:"description"`
 	Posts       []Post    `json:"posts,omitempty"`
+	// DeletedAt is set while the category is in the trash
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+}
+
+// ResourceType implements policy.Resource
+func (c *Category) ResourceType() string {
+	return "categories"
+}
+
+// OwnerID implements policy.Resource. Categories have no owner.
+func (c *Category) OwnerID() uint {
+	return 0
 }
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// EmailVerificationToken is a hashed, single-use token confirming that a
+// user owns their email address
+type EmailVerificationToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	Email     string     `gorm:"not null" json:"email"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
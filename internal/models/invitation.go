@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Invitation is a hashed, single-use token that lets the invitee create an
+// account with a pre-assigned role
+type Invitation struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Email          string     `gorm:"index;not null" json:"email"`
+	Role           string     `gorm:"not null" json:"role"`
+	TokenHash      string     `gorm:"uniqueIndex;not null" json:"-"`
+	InvitedByID    *uint      `gorm:"index" json:"invited_by_id"`
+	InvitedBy      *User      `gorm:"constraint:OnDelete:SET NULL" json:"invited_by,omitempty"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	AcceptedUserID *uint      `json:"accepted_user_id"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+}
+
+// IsPending reports whether the invitation can still be accepted
+func (i *Invitation) IsPending() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && time.Now().Before(i.ExpiresAt)
+}
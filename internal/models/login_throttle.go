@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginThrottle tracks failed login attempts for an account or client IP.
+// Key is "account:<email>" or "ip:<address>".
+type LoginThrottle struct {
+	Key           string     `gorm:"primarykey" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	BlockedUntil  *time.Time `json:"blocked_until"`
+	Locked        bool       `gorm:"default:false" json:"locked"`
+}
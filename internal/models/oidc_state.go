@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// OIDCLoginState holds the state of an OpenID Connect login between the
+// redirect to the provider and the callback. StateHash is the hash of the
+// state parameter; the nonce and PKCE verifier never leave the server.
+type OIDCLoginState struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	StateHash    string    `gorm:"uniqueIndex;not null" json:"-"`
+	Provider     string    `gorm:"not null" json:"provider"`
+	Nonce        string    `gorm:"not null" json:"-"`
+	CodeVerifier string    `gorm:"not null" json:"-"`
+	ExpiresAt    time.Time `gorm:"index;not null" json:"expires_at"`
+}
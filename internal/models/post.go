@@ -2,8 +2,36 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
+)
+
+// Post statuses
+const (
+	PostStatusDraft     = "draft"
+	PostStatusInReview  = "in_review"
+	PostStatusApproved  = "approved"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
 )
 
+// PostStatuses lists the valid post statuses
+var PostStatuses = []string{
+	PostStatusDraft, PostStatusInReview, PostStatusApproved,
+	PostStatusScheduled, PostStatusPublished, PostStatusArchived,
+}
+
+// IsPostStatus reports whether status is a valid post status
+func IsPostStatus(status string) bool {
+	for _, s := range PostStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
 type Post struct {
 	ID          uint      `gorm:"primarykey" json:"id"`
 	CreatedAt   time.Time `json:"created_at"`
@@ -12,10 +40,50 @@ type Post struct {
 	Content     string    `gorm:"type:text" json:"content"`
 	Slug        string    `gorm:"unique;not null" json:"slug"`
 	Published   bool      `gorm:"default:false" json:"published"`
+	Status      string    `gorm:"index;not null;default:draft" json:"status"`
 	UserID      uint      `json:"user_id"`
 	User        User      `json:"user"`
 	CategoryID  uint      `json:"category_id"`
 	Category    Category  `json:"category"`
 	Tags        []Tag     `gorm:"many2many:post_tags;" json:"tags"`
 	FeaturedImg string    `json:"featured_img"`
+	// PublishAt is when a scheduled post goes live
+	PublishAt *time.Time `gorm:"index" json:"publish_at"`
+	// UnpublishAt is when a published post is archived
+	UnpublishAt *time.Time `gorm:"index" json:"unpublish_at"`
+	// ReviewerID is the user assigned to review the post
+	ReviewerID *uint `gorm:"index" json:"reviewer_id"`
+	Reviewer   *User `gorm:"constraint:OnDelete:SET NULL" json:"reviewer,omitempty"`
+	// DeletedAt is set while the post is in the trash
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+}
+
+// BeforeSave is a GORM hook that keeps Published in line with the status
+func (p *Post) BeforeSave(tx *gorm.DB) error {
+	if p.Status == "" {
+		p.Status = PostStatusDraft
+	}
+	p.Published = p.Status == PostStatusPublished
+	return nil
+}
+
+// ResourceType implements policy.Resource
+func (p *Post) ResourceType() string {
+	return "posts"
+}
+
+// OwnerID implements policy.Resource
+func (p *Post) OwnerID() uint {
+	return p.UserID
+}
+
+// IsDraft implements policy.Draftable. Posts stop being drafts once they
+// are submitted for review.
+func (p *Post) IsDraft() bool {
+	return p.Status == "" || p.Status == PostStatusDraft
+}
+
+// IsPublished implements policy.Publishable
+func (p *Post) IsPublished() bool {
+	return p.Published
 }
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrRevisionImmutable = errors.New("post revisions cannot be changed")
+
+// PostRevision is an immutable snapshot of a post, taken whenever the post
+// is created, updated or restored
+type PostRevision struct {
+	ID          uint             `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time        `json:"created_at"`
+	PostID      uint             `gorm:"not null;uniqueIndex:idx_post_revision_number" json:"post_id"`
+	Number      int              `gorm:"not null;uniqueIndex:idx_post_revision_number" json:"number"`
+	UserID      *uint            `gorm:"index" json:"user_id"`
+	User        *User            `gorm:"constraint:OnDelete:SET NULL" json:"user,omitempty"`
+	Title       string           `json:"title"`
+	Content     string           `gorm:"type:text" json:"content,omitempty"`
+	Slug        string           `json:"slug"`
+	Published   bool             `json:"published"`
+	Status      string           `json:"status"`
+	FeaturedImg string           `json:"featured_img"`
+	CategoryID  uint             `json:"category_id"`
+	Category    RevisionCategory `gorm:"type:text" json:"category"`
+	Tags        RevisionTags     `gorm:"type:text" json:"tags"`
+	PublishAt   *time.Time       `json:"publish_at"`
+	UnpublishAt *time.Time       `json:"unpublish_at"`
+	// RestoredFrom is the number of the revision this one restored
+	RestoredFrom *int `json:"restored_from,omitempty"`
+}
+
+// BeforeUpdate keeps revisions immutable
+func (r *PostRevision) BeforeUpdate(tx *gorm.DB) error {
+	return ErrRevisionImmutable
+}
+
+// RevisionCategory is the category of a post as it was at a revision
+type RevisionCategory struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// Value implements driver.Valuer
+func (c RevisionCategory) Value() (driver.Value, error) {
+	return jsonValue(c)
+}
+
+// Scan implements sql.Scanner
+func (c *RevisionCategory) Scan(value interface{}) error {
+	return scanJSON(value, c)
+}
+
+// RevisionTag is a tag of a post as it was at a revision
+type RevisionTag struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// RevisionTags are the tags of a post as they were at a revision
+type RevisionTags []RevisionTag
+
+// Value implements driver.Valuer
+func (t RevisionTags) Value() (driver.Value, error) {
+	if t == nil {
+		t = RevisionTags{}
+	}
+	return jsonValue(t)
+}
+
+// Scan implements sql.Scanner
+func (t *RevisionTags) Scan(value interface{}) error {
+	return scanJSON(value, t)
+}
+
+// IDs returns the IDs of the tags
+func (t RevisionTags) IDs() []uint {
+	ids := make([]uint, len(t))
+	for i, tag := range t {
+		ids[i] = tag.ID
+	}
+	return ids
+}
+
+func jsonValue(v interface{}) (driver.Value, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func scanJSON(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	case []byte:
+		return json.Unmarshal(v, dest)
+	default:
+		return fmt.Errorf("cannot scan %T as JSON", value)
+	}
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// PostTransition records a workflow transition of a post
+type PostTransition struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	PostID     uint      `gorm:"index;not null" json:"post_id"`
+	UserID     *uint     `gorm:"index" json:"user_id"`
+	User       *User     `gorm:"constraint:OnDelete:SET NULL" json:"user,omitempty"`
+	Transition string    `gorm:"not null" json:"transition"`
+	FromStatus string    `gorm:"not null" json:"from_status"`
+	ToStatus   string    `gorm:"not null" json:"to_status"`
+	Comment    string    `gorm:"type:text" json:"comment"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is a hashed, single-use refresh token. Tokens issued from the
+// same login share a FamilyID so that reuse of a rotated token can revoke
+// the whole chain.
+type RefreshToken struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UserID       uint       `gorm:"index;not null" json:"user_id"`
+	TokenHash    string     `gorm:"uniqueIndex;not null" json:"-"`
+	FamilyID     string     `gorm:"index;not null" json:"family_id"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"replaced_by_id"`
+}
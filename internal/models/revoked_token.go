@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// RevokedToken records an access token that was revoked before its expiry
+type RevokedToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	JTI       string    `gorm:"column:jti;uniqueIndex;not null" json:"jti"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// Role is a named set of permissions that users are assigned through
+// User.Role
+type Role struct {
+	ID          uint         `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Name        string       `gorm:"unique;not null" json:"name"`
+	Description string       `json:"description"`
+	System      bool         `gorm:"default:false" json:"system"`
+	Permissions []Permission `gorm:"many2many:role_permissions;" json:"permissions"`
+}
+
+// Permission is a single action that can be granted to roles, such as
+// "posts.publish"
+type Permission struct {
+	ID          uint   `gorm:"primarykey" json:"id"`
+	Name        string `gorm:"unique;not null" json:"name"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// Setting is a runtime configuration value that admins can change without
+// a redeploy
+type Setting struct {
+	Key       string    `gorm:"primarykey" json:"key"`
+	Value     string    `gorm:"type:text" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
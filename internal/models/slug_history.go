@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// SlugHistory keeps a former slug of a post or category, so that links
+// using it can be redirected to the current slug
+type SlugHistory struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	ResourceType string    `gorm:"not null;uniqueIndex:idx_slug_history_slug;index:idx_slug_history_resource" json:"resource_type"`
+	Slug         string    `gorm:"not null;uniqueIndex:idx_slug_history_slug" json:"slug"`
+	ResourceID   uint      `gorm:"not null;index:idx_slug_history_resource" json:"resource_id"`
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Tag struct {
@@ -11,4 +13,17 @@ type Tag struct {
 	Name      string    `gorm:"not null" json:"name"`
 	Slug      string    `gorm:"unique;not null" json:"slug"`
 	Posts     []Post    `gorm:"many2many:post_tags;" json:"posts,omitempty"`
+	PostCount int64     `gorm:"->;-:migration" json:"post_count"`
+	// DeletedAt is set while the tag is in the trash
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+}
+
+// ResourceType implements policy.Resource
+func (t *Tag) ResourceType() string {
+	return "tags"
+}
+
+// OwnerID implements policy.Resource. Tags have no owner.
+func (t *Tag) OwnerID() uint {
+	return 0
 }
@@ -3,24 +3,60 @@ package models
 import (
 	"time"
 
+	"github.com/truncgil/gorecta/pkg/auth"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type User struct {
-	ID        uint      `gorm:"primarykey" json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Email     string    `gorm:"unique;not null" json:"email"`
-	Password  string    `gorm:"not null" json:"-"`
-	Name      string    `gorm:"not null" json:"name"`
-	Role      string    `gorm:"default:user" json:"role"`
-	Active    bool      `gorm:"default:true" json:"active"`
+	ID               uint       `gorm:"primarykey" json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	Email            string     `gorm:"unique;not null" json:"email"`
+	Password         string     `gorm:"not null" json:"-"`
+	Name             string     `gorm:"not null" json:"name"`
+	Role             string     `gorm:"default:user" json:"role"`
+	Active           bool       `gorm:"default:true" json:"active"`
+	TokensValidAfter time.Time  `json:"-"`
+	SuspendedAt      *time.Time `json:"suspended_at,omitempty"`
+	SuspendedUntil   *time.Time `json:"suspended_until,omitempty"`
+	SuspendedReason  string     `json:"suspended_reason,omitempty"`
+	EmailVerified    bool       `gorm:"default:false" json:"email_verified"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at,omitempty"`
+	TwoFactorEnabled bool       `gorm:"default:false" json:"two_factor_enabled"`
+	TOTPSecret       string     `json:"-"`
+	TOTPLastCounter  int64      `json:"-"`
+}
+
+// IsActive reports whether the user may sign in. An inactive user whose
+// suspension has an expiry counts as active once that expiry has passed.
+func (u *User) IsActive() bool {
+	if u.Active {
+		return true
+	}
+	return u.SuspendedUntil != nil && time.Now().After(*u.SuspendedUntil)
+}
+
+// Suspend deactivates the user with a reason and an optional expiry
+func (u *User) Suspend(reason string, until *time.Time) {
+	now := time.Now()
+	u.Active = false
+	u.SuspendedAt = &now
+	u.SuspendedUntil = until
+	u.SuspendedReason = reason
+}
+
+// Reactivate clears any suspension and marks the user as active
+func (u *User) Reactivate() {
+	u.Active = true
+	u.SuspendedAt = nil
+	u.SuspendedUntil = nil
+	u.SuspendedReason = ""
 }
 
 // BeforeCreate is a GORM hook that hashes the password before creating the user
 func (u *User) BeforeCreate(tx *gorm.DB) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), auth.PasswordHashCost())
 	if err != nil {
 		return err
 	}
@@ -35,10 +71,17 @@ func (u *User) ComparePassword(password string) error {
 
 // UpdatePassword updates the user's password
 func (u *User) UpdatePassword(password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), auth.PasswordHashCost())
 	if err != nil {
 		return err
 	}
 	u.Password = string(hashedPassword)
 	return nil
-} 
\ No newline at end of file
+}
+
+// NeedsRehash reports whether the password hash was made with a bcrypt cost
+// other than the configured one
+func (u *User) NeedsRehash() bool {
+	cost, err := bcrypt.Cost([]byte(u.Password))
+	return err == nil && cost != auth.PasswordHashCost()
+}
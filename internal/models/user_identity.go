@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider, identified by the provider's subject
+type UserIdentity struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	Provider    string    `gorm:"uniqueIndex:idx_user_identity_subject;not null" json:"provider"`
+	Subject     string    `gorm:"uniqueIndex:idx_user_identity_subject;not null" json:"subject"`
+	Email       string    `json:"email"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
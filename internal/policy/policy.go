@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"errors"
+
+	"github.com/truncgil/gorecta/internal/service"
+)
+
+// Action is an operation on a resource
+type Action string
+
+const (
+	ActionRead    Action = "read"
+	ActionUpdate  Action = "update"
+	ActionDelete  Action = "delete"
+	ActionPublish Action = "publish"
+)
+
+var ErrForbidden = errors.New("insufficient permissions")
+
+// Resource is anything policies can be evaluated against. Permissions are
+// named "<ResourceType>.<action>", e.g. "posts.update".
+type Resource interface {
+	ResourceType() string
+	// OwnerID returns the ID of the user who owns the resource, or 0 if the
+	// resource has no owner
+	OwnerID() uint
+}
+
+// Draftable is implemented by resources that have a draft state, in which
+// their owners may still change them
+type Draftable interface {
+	IsDraft() bool
+}
+
+// Publishable is implemented by resources that are hidden until published
+type Publishable interface {
+	IsPublished() bool
+}
+
+// Subject is the user an action is authorized for
+type Subject struct {
+	UserID uint
+	Role   string
+}
+
+// Authorize checks whether the subject may perform the action on the
+// resource:
+//
+//   - "<type>.<action>" allows the action on any resource of the type
+//   - "<type>.<action>_own" allows the action on resources the subject owns;
+//     updating or deleting them only while they are still drafts
+//   - unpublished resources can only be read by their owner or by subjects
+//     that may update any resource of the type
+func Authorize(subject Subject, action Action, resource Resource) error {
+	resourceType := resource.ResourceType()
+	owned := resource.OwnerID() != 0 && resource.OwnerID() == subject.UserID
+
+	draft := false
+	if d, ok := resource.(Draftable); ok {
+		draft = d.IsDraft()
+	}
+
+	switch action {
+	case ActionRead:
+		hidden := false
+		if p, ok := resource.(Publishable); ok {
+			hidden = !p.IsPublished()
+		}
+		if !hidden || owned {
+			return require(subject, resourceType+".read")
+		}
+		return require(subject, resourceType+".read", resourceType+".update")
+
+	case ActionUpdate, ActionDelete:
+		allowed, err := granted(subject, resourceType+"."+string(action))
+		if err != nil || allowed {
+			return result(allowed, err)
+		}
+
+		if !owned {
+			return ErrForbidden
+		}
+		if _, ok := resource.(Draftable); ok && !draft {
+			return ErrForbidden
+		}
+		return require(subject, resourceType+"."+string(action)+"_own")
+
+	default:
+		allowed, err := granted(subject, resourceType+"."+string(action))
+		if err != nil || allowed || !owned {
+			return result(allowed, err)
+		}
+		return require(subject, resourceType+"."+string(action)+"_own")
+	}
+}
+
+// CanReadAllDrafts reports whether the subject may read drafts of every
+// resource of the type, which list endpoints use to decide on filtering
+func CanReadAllDrafts(subject Subject, resourceType string) (bool, error) {
+	return granted(subject, resourceType+".update")
+}
+
+// CanDeleteAll reports whether the subject may delete every resource of the
+// type, which the trash uses to decide on filtering
+func CanDeleteAll(subject Subject, resourceType string) (bool, error) {
+	return granted(subject, resourceType+".delete")
+}
+
+// require returns ErrForbidden unless the subject holds every permission
+func require(subject Subject, permissions ...string) error {
+	for _, permission := range permissions {
+		allowed, err := granted(subject, permission)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return ErrForbidden
+		}
+	}
+	return nil
+}
+
+// granted looks up a permission of the subject's role. It is a variable so
+// that tests can replace the role lookup.
+var granted = func(subject Subject, permission string) (bool, error) {
+	return service.RoleHasPermission(subject.Role, permission)
+}
+
+func result(allowed bool, err error) error {
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+)
+
+// testRoles are cut-down versions of the default roles
+var testRoles = map[string][]string{
+	"editor": {"posts.read", "posts.create", "posts.update", "posts.publish", "tags.read", "tags.update"},
+	"author": {"posts.read", "posts.create", "posts.update_own", "posts.delete_own", "posts.submit_own", "tags.read"},
+	"reader": {"posts.read", "tags.read"},
+}
+
+var errLookup = errors.New("lookup failed")
+
+func useTestRoles(t *testing.T) {
+	t.Helper()
+	previous := granted
+	t.Cleanup(func() { granted = previous })
+
+	granted = func(subject Subject, permission string) (bool, error) {
+		if subject.Role == "broken" {
+			return false, errLookup
+		}
+		for _, p := range testRoles[subject.Role] {
+			if p == permission {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+type testPost struct {
+	owner     uint
+	draft     bool
+	published bool
+}
+
+func (p testPost) ResourceType() string { return "posts" }
+func (p testPost) OwnerID() uint        { return p.owner }
+func (p testPost) IsDraft() bool        { return p.draft }
+func (p testPost) IsPublished() bool    { return p.published }
+
+type testTag struct{}
+
+func (testTag) ResourceType() string { return "tags" }
+func (testTag) OwnerID() uint        { return 0 }
+
+func TestAuthorize(t *testing.T) {
+	useTestRoles(t)
+
+	editor := Subject{UserID: 1, Role: "editor"}
+	author := Subject{UserID: 2, Role: "author"}
+	reader := Subject{UserID: 3, Role: "reader"}
+
+	ownDraft := testPost{owner: 2, draft: true}
+	ownSubmitted := testPost{owner: 2}
+	ownPublished := testPost{owner: 2, published: true}
+	othersDraft := testPost{owner: 4, draft: true}
+	othersPublished := testPost{owner: 4, published: true}
+
+	tests := []struct {
+		name     string
+		subject  Subject
+		action   Action
+		resource Resource
+		want     error
+	}{
+		{"read published", reader, ActionRead, othersPublished, nil},
+		{"read others' draft", reader, ActionRead, othersDraft, ErrForbidden},
+		{"read own draft", author, ActionRead, ownDraft, nil},
+		{"author reads others' draft", author, ActionRead, othersDraft, ErrForbidden},
+		{"editor reads others' draft", editor, ActionRead, othersDraft, nil},
+
+		{"update own draft", author, ActionUpdate, ownDraft, nil},
+		{"update own submitted post", author, ActionUpdate, ownSubmitted, ErrForbidden},
+		{"update own published post", author, ActionUpdate, ownPublished, ErrForbidden},
+		{"update others' draft", author, ActionUpdate, othersDraft, ErrForbidden},
+		{"editor updates any post", editor, ActionUpdate, othersPublished, nil},
+		{"reader updates own draft", reader, ActionUpdate, testPost{owner: 3, draft: true}, ErrForbidden},
+
+		{"delete own draft", author, ActionDelete, ownDraft, nil},
+		{"delete own published post", author, ActionDelete, ownPublished, ErrForbidden},
+		{"editor deletes without posts.delete", editor, ActionDelete, othersDraft, ErrForbidden},
+
+		{"submit own post", author, Action("submit"), ownDraft, nil},
+		{"submit own post after review", author, Action("submit"), ownSubmitted, nil},
+		{"submit others' post", author, Action("submit"), othersDraft, ErrForbidden},
+		{"publish", editor, ActionPublish, othersDraft, nil},
+		{"author publishes own post", author, ActionPublish, ownDraft, ErrForbidden},
+
+		{"update tag", editor, ActionUpdate, testTag{}, nil},
+		{"author updates tag", author, ActionUpdate, testTag{}, ErrForbidden},
+		{"unowned resources are not owned by user 0", Subject{Role: "author"}, ActionUpdate, testPost{draft: true}, ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Authorize(tt.subject, tt.action, tt.resource); err != tt.want {
+				t.Errorf("Authorize = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeLookupError(t *testing.T) {
+	useTestRoles(t)
+
+	broken := Subject{UserID: 1, Role: "broken"}
+	for _, action := range []Action{ActionRead, ActionUpdate, ActionDelete, ActionPublish} {
+		if err := Authorize(broken, action, testPost{owner: 1, draft: true}); !errors.Is(err, errLookup) {
+			t.Errorf("Authorize(%s) = %v, want the lookup error", action, err)
+		}
+	}
+
+	if _, err := CanReadAllDrafts(broken, "posts"); !errors.Is(err, errLookup) {
+		t.Errorf("CanReadAllDrafts = %v, want the lookup error", err)
+	}
+	if _, err := CanDeleteAll(broken, "posts"); !errors.Is(err, errLookup) {
+		t.Errorf("CanDeleteAll = %v, want the lookup error", err)
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/pkg/auth"
+	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
+)
+
+// apiKeyUsageInterval limits how often last-used tracking writes to the database
+const apiKeyUsageInterval = time.Minute
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// AuthenticateAPIKey resolves an API key to the key record and its owner
+func AuthenticateAPIKey(key, ip string) (*models.APIKey, *models.User, error) {
+	keyID, ok := auth.APIKeyID(key)
+	if !ok {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	db := database.GetDB()
+
+	var apiKey models.APIKey
+	if err := db.Where("key_id = ?", keyID).First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(auth.HashToken(key))) != 1 || !apiKey.IsValid() {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var user models.User
+	if err := db.First(&user, apiKey.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+
+	if !user.IsActive() {
+		return nil, nil, ErrUserInactive
+	}
+
+	// Record usage, but not on every single request
+	now := time.Now()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyUsageInterval || apiKey.LastUsedIP != ip {
+		if err := db.Model(&apiKey).UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ip,
+		}).Error; err != nil {
+			log.Printf("Failed to record API key usage: %v", err)
+		}
+	}
+
+	return &apiKey, &user, nil
+}
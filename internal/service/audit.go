@@ -0,0 +1,30 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/truncgil/gorecta/internal/models"
+	"gorm.io/gorm"
+)
+
+// RecordAudit stores an audit event. Failures are logged rather than
+// returned so that auditing never breaks the audited action.
+func RecordAudit(db *gorm.DB, action string, actorID, targetUserID *uint, ip string, details map[string]interface{}) {
+	event := models.AuditEvent{
+		Action:       action,
+		ActorID:      actorID,
+		TargetUserID: targetUserID,
+		IP:           ip,
+	}
+
+	if len(details) > 0 {
+		if b, err := json.Marshal(details); err == nil {
+			event.Details = string(b)
+		}
+	}
+
+	if err := db.Create(&event).Error; err != nil {
+		log.Printf("Failed to record audit event %s: %v", action, err)
+	}
+}
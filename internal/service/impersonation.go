@@ -0,0 +1,38 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
+)
+
+// ImpersonatePermission allows acting as other users
+const ImpersonatePermission = "users.impersonate"
+
+// ValidateImpersonator checks that the admin behind an impersonation token
+// may still impersonate. Suspending the admin or taking away the permission
+// ends their impersonation sessions immediately.
+func ValidateImpersonator(actorID uint) error {
+	var actor models.User
+	if err := database.GetDB().Select("id", "role", "active", "suspended_until").First(&actor, actorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenRevoked
+		}
+		return err
+	}
+
+	if !actor.IsActive() {
+		return ErrTokenRevoked
+	}
+
+	allowed, err := RoleHasPermission(actor.Role, ImpersonatePermission)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrTokenRevoked
+	}
+	return nil
+}
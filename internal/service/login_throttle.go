@@ -0,0 +1,203 @@
+package service
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// loginThrottleConfig controls backoff and lockout of failed logins
+type loginThrottleConfig struct {
+	freeAttempts     int
+	backoffBase      time.Duration
+	backoffMax       time.Duration
+	lockoutThreshold int
+	lockoutDuration  time.Duration
+	window           time.Duration
+}
+
+func accountThrottleConfig() loginThrottleConfig {
+	return loginThrottleConfig{
+		freeAttempts:     envInt("LOGIN_FREE_ATTEMPTS", 3),
+		backoffBase:      envDuration("LOGIN_BACKOFF_BASE", time.Second),
+		backoffMax:       envDuration("LOGIN_BACKOFF_MAX", 5*time.Minute),
+		lockoutThreshold: envInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+		lockoutDuration:  envDuration("LOGIN_LOCKOUT_DURATION", 30*time.Minute),
+		window:           envDuration("LOGIN_ATTEMPT_WINDOW", time.Hour),
+	}
+}
+
+func ipThrottleConfig() loginThrottleConfig {
+	return loginThrottleConfig{
+		freeAttempts:     envInt("LOGIN_IP_FREE_ATTEMPTS", 20),
+		backoffBase:      envDuration("LOGIN_BACKOFF_BASE", time.Second),
+		backoffMax:       envDuration("LOGIN_BACKOFF_MAX", 5*time.Minute),
+		lockoutThreshold: envInt("LOGIN_IP_LOCKOUT_THRESHOLD", 100),
+		lockoutDuration:  envDuration("LOGIN_LOCKOUT_DURATION", 30*time.Minute),
+		window:           envDuration("LOGIN_ATTEMPT_WINDOW", time.Hour),
+	}
+}
+
+// LoginRetryAfter returns how long the client has to wait before another
+// login attempt for the email from the IP is accepted. Zero means allowed.
+func LoginRetryAfter(email, ip string) (time.Duration, error) {
+	var throttles []models.LoginThrottle
+	if err := database.GetDB().
+		Where("key IN ?", []string{accountThrottleKey(email), ipThrottleKey(ip)}).
+		Find(&throttles).Error; err != nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	for _, t := range throttles {
+		if t.BlockedUntil != nil {
+			if d := time.Until(*t.BlockedUntil); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait, nil
+}
+
+// RecordLoginFailure counts a failed login for the email and IP, applying
+// exponential backoff and locking them once the lockout threshold is reached
+func RecordLoginFailure(email, ip string) error {
+	db := database.GetDB()
+	email = normalizeEmail(email)
+
+	locked, err := recordFailure(db, accountThrottleKey(email), accountThrottleConfig())
+	if err != nil {
+		return err
+	}
+	if locked {
+		var user models.User
+		var target *uint
+		if db.Select("id").Where("LOWER(email) = ?", email).First(&user).Error == nil {
+			target = &user.ID
+		}
+		RecordAudit(db, "login.account_locked", nil, target, ip, map[string]interface{}{"email": email})
+	}
+
+	locked, err = recordFailure(db, ipThrottleKey(ip), ipThrottleConfig())
+	if err != nil {
+		return err
+	}
+	if locked {
+		RecordAudit(db, "login.ip_locked", nil, nil, ip, nil)
+	}
+
+	return nil
+}
+
+// RecordLoginSuccess clears the failed attempts of the account
+func RecordLoginSuccess(email string) error {
+	return database.GetDB().Where("key = ?", accountThrottleKey(email)).Delete(&models.LoginThrottle{}).Error
+}
+
+// UnlockAccount lifts a lockout or backoff of the account
+func UnlockAccount(db *gorm.DB, email string) error {
+	return db.Where("key = ?", accountThrottleKey(email)).Delete(&models.LoginThrottle{}).Error
+}
+
+// PurgeLoginThrottles removes the failed attempts of accounts and IPs that
+// are neither blocked nor failed within the attempt window
+func PurgeLoginThrottles(db *gorm.DB) error {
+	now := time.Now()
+	return db.Where("last_failure_at < ? AND (blocked_until IS NULL OR blocked_until < ?)",
+		now.Add(-accountThrottleConfig().window), now).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+// recordFailure increments the failure counter for key and reports whether
+// this failure newly locked it
+func recordFailure(db *gorm.DB, key string, cfg loginThrottleConfig) (bool, error) {
+	newlyLocked := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{Key: key}).Error; err != nil {
+			return err
+		}
+
+		var throttle models.LoginThrottle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ?", key).First(&throttle).Error; err != nil {
+			return err
+		}
+
+		newlyLocked = applyFailure(&throttle, time.Now(), cfg)
+		return tx.Save(&throttle).Error
+	})
+
+	return newlyLocked, err
+}
+
+// applyFailure counts a failure at now against the throttle and blocks it
+// for a backoff or lockout. It reports whether the failure locked it.
+func applyFailure(throttle *models.LoginThrottle, now time.Time, cfg loginThrottleConfig) bool {
+	// Start over once failures are older than the window or a lockout has
+	// run out, so that every lockout takes the full threshold again
+	expired := throttle.Locked && throttle.BlockedUntil != nil && !now.Before(*throttle.BlockedUntil)
+	if now.Sub(throttle.LastFailureAt) > cfg.window || expired {
+		throttle.Failures = 0
+		throttle.Locked = false
+	}
+
+	throttle.Failures++
+	throttle.LastFailureAt = now
+
+	switch {
+	case throttle.Failures >= cfg.lockoutThreshold:
+		until := now.Add(cfg.lockoutDuration)
+		throttle.BlockedUntil = &until
+		newlyLocked := !throttle.Locked
+		throttle.Locked = true
+		return newlyLocked
+	case throttle.Failures > cfg.freeAttempts:
+		until := now.Add(backoff(throttle.Failures-cfg.freeAttempts, cfg))
+		throttle.BlockedUntil = &until
+	}
+	return false
+}
+
+// backoff returns base * 2^(n-1), capped at the configured maximum
+func backoff(n int, cfg loginThrottleConfig) time.Duration {
+	d := float64(cfg.backoffBase) * math.Pow(2, float64(n-1))
+	if d > float64(cfg.backoffMax) {
+		return cfg.backoffMax
+	}
+	return time.Duration(d)
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + normalizeEmail(email)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/truncgil/gorecta/internal/models"
+)
+
+var testThrottleConfig = loginThrottleConfig{
+	freeAttempts:     3,
+	backoffBase:      time.Second,
+	backoffMax:       5 * time.Minute,
+	lockoutThreshold: 10,
+	lockoutDuration:  30 * time.Minute,
+	window:           time.Hour,
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{9, 256 * time.Second},
+		{10, 5 * time.Minute},
+		{60, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.n, testThrottleConfig); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestApplyFailure(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cfg := testThrottleConfig
+
+	tests := []struct {
+		name        string
+		throttle    models.LoginThrottle
+		now         time.Time
+		wantLocked  bool
+		wantFails   int
+		wantBlocked time.Duration // from now; zero means not blocked
+	}{
+		{
+			name:      "free attempt",
+			throttle:  models.LoginThrottle{Failures: 1, LastFailureAt: start},
+			now:       start.Add(time.Second),
+			wantFails: 2,
+		},
+		{
+			name:        "first backoff",
+			throttle:    models.LoginThrottle{Failures: 3, LastFailureAt: start},
+			now:         start.Add(time.Second),
+			wantFails:   4,
+			wantBlocked: time.Second,
+		},
+		{
+			name:        "growing backoff",
+			throttle:    models.LoginThrottle{Failures: 5, LastFailureAt: start},
+			now:         start.Add(time.Second),
+			wantFails:   6,
+			wantBlocked: 4 * time.Second,
+		},
+		{
+			name:        "lockout",
+			throttle:    models.LoginThrottle{Failures: 9, LastFailureAt: start},
+			now:         start.Add(time.Second),
+			wantLocked:  true,
+			wantFails:   10,
+			wantBlocked: cfg.lockoutDuration,
+		},
+		{
+			name:      "failures outside the window start over",
+			throttle:  models.LoginThrottle{Failures: 9, LastFailureAt: start},
+			now:       start.Add(cfg.window + time.Second),
+			wantFails: 1,
+		},
+		{
+			name: "expired lockout starts over inside the window",
+			throttle: models.LoginThrottle{
+				Failures:      10,
+				LastFailureAt: start,
+				BlockedUntil:  timePtr(start.Add(cfg.lockoutDuration)),
+				Locked:        true,
+			},
+			now:       start.Add(cfg.lockoutDuration + time.Minute),
+			wantFails: 1,
+		},
+		{
+			name: "failure during a lockout extends it without a new audit",
+			throttle: models.LoginThrottle{
+				Failures:      10,
+				LastFailureAt: start,
+				BlockedUntil:  timePtr(start.Add(cfg.lockoutDuration)),
+				Locked:        true,
+			},
+			now:         start.Add(time.Minute),
+			wantFails:   11,
+			wantBlocked: cfg.lockoutDuration,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := tt.throttle
+			locked := applyFailure(&throttle, tt.now, cfg)
+
+			if locked != tt.wantLocked {
+				t.Errorf("locked = %v, want %v", locked, tt.wantLocked)
+			}
+			if throttle.Failures != tt.wantFails {
+				t.Errorf("Failures = %d, want %d", throttle.Failures, tt.wantFails)
+			}
+			if !throttle.LastFailureAt.Equal(tt.now) {
+				t.Errorf("LastFailureAt = %v, want %v", throttle.LastFailureAt, tt.now)
+			}
+
+			var blocked time.Duration
+			if throttle.BlockedUntil != nil && throttle.BlockedUntil.After(tt.now) {
+				blocked = throttle.BlockedUntil.Sub(tt.now)
+			}
+			if blocked != tt.wantBlocked {
+				t.Errorf("blocked for %v, want %v", blocked, tt.wantBlocked)
+			}
+		})
+	}
+}
+
+// One wrong password after each lockout must not keep the account locked
+func TestApplyFailureAfterLockoutNeedsFullThreshold(t *testing.T) {
+	cfg := testThrottleConfig
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var throttle models.LoginThrottle
+
+	lockouts := 0
+	for round := 0; round < 3; round++ {
+		for i := 0; i < cfg.lockoutThreshold; i++ {
+			if throttle.BlockedUntil != nil && now.Before(*throttle.BlockedUntil) {
+				now = *throttle.BlockedUntil
+			}
+			if applyFailure(&throttle, now, cfg) {
+				lockouts++
+				if i != cfg.lockoutThreshold-1 {
+					t.Fatalf("round %d: locked after %d failures, want %d", round, i+1, cfg.lockoutThreshold)
+				}
+			}
+		}
+	}
+
+	if lockouts != 3 {
+		t.Errorf("audited %d lockouts, want 3", lockouts)
+	}
+}
+
+func TestAccountThrottleKeyNormalizesEmail(t *testing.T) {
+	if got, want := accountThrottleKey("  Jane@Example.COM "), "account:jane@example.com"; got != want {
+		t.Errorf("accountThrottleKey = %q, want %q", got, want)
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
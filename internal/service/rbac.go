@@ -0,0 +1,240 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
+)
+
+// Permissions known to the application
+var DefaultPermissions = []models.Permission{
+	{Name: "posts.read", Description: "View posts"},
+	{Name: "posts.create", Description: "Create posts"},
+	{Name: "posts.update", Description: "Update any post"},
+	{Name: "posts.update_own", Description: "Update own draft posts"},
+	{Name: "posts.delete", Description: "Delete any post"},
+	{Name: "posts.delete_own", Description: "Delete own draft posts"},
+	{Name: "posts.publish", Description: "Publish and unpublish posts"},
+	{Name: "posts.submit", Description: "Submit any post for review"},
+	{Name: "posts.submit_own", Description: "Submit own posts for review"},
+	{Name: "posts.review", Description: "Approve posts or send them back to their authors"},
+	{Name: "categories.read", Description: "View categories"},
+	{Name: "categories.create", Description: "Create categories"},
+	{Name: "categories.update", Description: "Update categories"},
+	{Name: "categories.delete", Description: "Delete categories"},
+	{Name: "tags.read", Description: "View tags"},
+	{Name: "tags.create", Description: "Create tags"},
+	{Name: "tags.update", Description: "Update tags"},
+	{Name: "tags.delete", Description: "Delete tags"},
+	{Name: "users.read", Description: "View any user"},
+	{Name: "users.manage", Description: "Change, suspend and delete any user"},
+	{Name: "users.impersonate", Description: "Act as other users"},
+	{Name: "roles.manage", Description: "Create roles and assign permissions"},
+	{Name: "settings.manage", Description: "Change runtime settings"},
+	{Name: "api_keys.manage", Description: "View and revoke any user's API keys"},
+}
+
+// defaultRoles maps the built-in roles to their initial permissions. The
+// admin role always holds every permission.
+var defaultRoles = []struct {
+	name        string
+	description string
+	permissions []string
+}{
+	{"admin", "Full access to all endpoints", nil},
+	{"editor", "Can manage content but not users", []string{
+		"posts.read", "posts.create", "posts.update", "posts.publish",
+		"posts.submit", "posts.review", "categories.read", "tags.read",
+	}},
+	{"author", "Can write posts and edit their own drafts", []string{
+		"posts.read", "posts.create", "posts.update_own", "posts.delete_own",
+		"posts.submit_own", "categories.read", "tags.read",
+	}},
+	{"user", "Can view content and manage their own profile", []string{
+		"posts.read", "categories.read", "tags.read",
+	}},
+}
+
+// workflowPermissionsSetting records that the editorial workflow permissions
+// were granted to built-in roles created before the workflow existed
+const workflowPermissionsSetting = "workflow_permissions_granted"
+
+// workflowPermissions are the permissions the editorial workflow added to
+// the defaults of built-in roles
+var workflowPermissions = map[string][]string{
+	"editor": {"posts.submit", "posts.review"},
+	"author": {"posts.submit_own"},
+}
+
+// AdminRole is the built-in role that always holds every permission
+const AdminRole = "admin"
+
+// permissionCacheTTL bounds how long other replicas may serve stale permissions
+const permissionCacheTTL = time.Minute
+
+var permissionCache = struct {
+	sync.RWMutex
+	roles    map[string]map[string]bool
+	loadedAt time.Time
+}{}
+
+// SeedRoles creates missing permissions and built-in roles. Existing roles
+// keep their permissions, except admin, which is granted every permission,
+// and built-in roles that are granted the workflow permissions once.
+func SeedRoles(db *gorm.DB) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range DefaultPermissions {
+			permission := p
+			if err := tx.Where(models.Permission{Name: p.Name}).
+				Attrs(models.Permission{Description: p.Description}).
+				FirstOrCreate(&permission).Error; err != nil {
+				return err
+			}
+		}
+
+		var all []models.Permission
+		if err := tx.Find(&all).Error; err != nil {
+			return err
+		}
+
+		for _, r := range defaultRoles {
+			var role models.Role
+			result := tx.Where("name = ?", r.name).Limit(1).Find(&role)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			if result.RowsAffected == 0 {
+				role = models.Role{Name: r.name, Description: r.description, System: true}
+				if r.permissions != nil {
+					role.Permissions = filterPermissions(all, r.permissions)
+				}
+				if r.name == AdminRole {
+					role.Permissions = all
+				}
+				if err := tx.Create(&role).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			if r.name == AdminRole {
+				if err := tx.Model(&role).Association("Permissions").Replace(all); err != nil {
+					return err
+				}
+			}
+		}
+
+		return grantWorkflowPermissions(tx, all)
+	})
+	if err != nil {
+		return err
+	}
+
+	InvalidatePermissionCache()
+	return nil
+}
+
+// grantWorkflowPermissions grants the editorial workflow permissions to the
+// built-in roles the first time it runs. Later changes made to these roles
+// are left alone.
+func grantWorkflowPermissions(tx *gorm.DB, all []models.Permission) error {
+	var count int64
+	if err := tx.Model(&models.Setting{}).Where("key = ?", workflowPermissionsSetting).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	for name, permissions := range workflowPermissions {
+		var role models.Role
+		result := tx.Where("name = ? AND system = ?", name, true).Limit(1).Find(&role)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := tx.Model(&role).Association("Permissions").Append(filterPermissions(all, permissions)); err != nil {
+			return err
+		}
+	}
+
+	return SetSetting(tx, workflowPermissionsSetting, "true")
+}
+
+// RoleHasPermission reports whether the named role grants the permission
+func RoleHasPermission(role, permission string) (bool, error) {
+	roles, err := rolePermissions()
+	if err != nil {
+		return false, err
+	}
+	return roles[role][permission], nil
+}
+
+// RoleExists reports whether a role with the name exists
+func RoleExists(role string) (bool, error) {
+	roles, err := rolePermissions()
+	if err != nil {
+		return false, err
+	}
+	_, ok := roles[role]
+	return ok, nil
+}
+
+// InvalidatePermissionCache forces role permissions to be reloaded
+func InvalidatePermissionCache() {
+	permissionCache.Lock()
+	permissionCache.roles = nil
+	permissionCache.Unlock()
+}
+
+// rolePermissions returns the permissions of every role, keyed by role name
+func rolePermissions() (map[string]map[string]bool, error) {
+	permissionCache.RLock()
+	roles, loadedAt := permissionCache.roles, permissionCache.loadedAt
+	permissionCache.RUnlock()
+	if roles != nil && time.Since(loadedAt) < permissionCacheTTL {
+		return roles, nil
+	}
+
+	var all []models.Role
+	if err := database.GetDB().Preload("Permissions").Find(&all).Error; err != nil {
+		return nil, err
+	}
+
+	roles = make(map[string]map[string]bool, len(all))
+	for _, role := range all {
+		granted := make(map[string]bool, len(role.Permissions))
+		for _, p := range role.Permissions {
+			granted[p.Name] = true
+		}
+		roles[role.Name] = granted
+	}
+
+	permissionCache.Lock()
+	permissionCache.roles = roles
+	permissionCache.loadedAt = time.Now()
+	permissionCache.Unlock()
+
+	return roles, nil
+}
+
+func filterPermissions(all []models.Permission, names []string) []models.Permission {
+	wanted := make(map[string]bool, len(names))
+	for _, n := range names {
+		wanted[n] = true
+	}
+
+	filtered := []models.Permission{}
+	for _, p := range all {
+		if wanted[p.Name] {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
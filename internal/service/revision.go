@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/truncgil/gorecta/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecordPostRevision snapshots the post as currently stored, including its
+// category and tags, as the next revision. Call it inside the transaction
+// that changed the post.
+func RecordPostRevision(tx *gorm.DB, postID, userID uint, restoredFrom *int) (*models.PostRevision, error) {
+	// Locking the post serializes revision numbers of concurrent updates
+	var post models.Post
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&post, postID).Error; err != nil {
+		return nil, err
+	}
+
+	var number int
+	if err := tx.Model(&models.PostRevision{}).Where("post_id = ?", postID).
+		Select("COALESCE(MAX(number), 0)").Scan(&number).Error; err != nil {
+		return nil, err
+	}
+
+	revision, err := snapshotPost(tx, &post)
+	if err != nil {
+		return nil, err
+	}
+	revision.Number = number + 1
+	revision.UserID = &userID
+	revision.RestoredFrom = restoredFrom
+
+	if err := tx.Create(revision).Error; err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// EnsureBaseRevision records the stored state of a post that predates
+// revision tracking as its first revision, attributed to its author, so that
+// the first tracked update can be undone
+func EnsureBaseRevision(tx *gorm.DB, postID uint) error {
+	var post models.Post
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&post, postID).Error; err != nil {
+		return err
+	}
+
+	var count int64
+	if err := tx.Model(&models.PostRevision{}).Where("post_id = ?", postID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	revision, err := snapshotPost(tx, &post)
+	if err != nil {
+		return err
+	}
+	revision.Number = 1
+	revision.UserID = &post.UserID
+	revision.CreatedAt = post.UpdatedAt
+	return tx.Create(revision).Error
+}
+
+func snapshotPost(tx *gorm.DB, post *models.Post) (*models.PostRevision, error) {
+	revision := models.PostRevision{
+		PostID:      post.ID,
+		Title:       post.Title,
+		Content:     post.Content,
+		Slug:        post.Slug,
+		Published:   post.Published,
+		Status:      post.Status,
+		PublishAt:   post.PublishAt,
+		UnpublishAt: post.UnpublishAt,
+		FeaturedImg: post.FeaturedImg,
+		CategoryID:  post.CategoryID,
+		Tags:        models.RevisionTags{},
+	}
+
+	var category models.Category
+	err := tx.First(&category, post.CategoryID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		revision.Category = models.RevisionCategory{ID: category.ID, Name: category.Name, Slug: category.Slug}
+	}
+
+	var tags []models.Tag
+	if err := tx.Select("tags.*").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Where("post_tags.post_id = ?", post.ID).
+		Order("tags.name").
+		Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		revision.Tags = append(revision.Tags, models.RevisionTag{ID: tag.ID, Name: tag.Name, Slug: tag.Slug})
+	}
+
+	return &revision, nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/pkg/auth"
+	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTokenRevoked = errors.New("token has been revoked")
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserInactive = errors.New("user account is inactive")
+)
+
+// revocationCache keeps revoked token IDs in memory until they expire so that
+// repeated use of a revoked token does not hit the database.
+var revocationCache = struct {
+	sync.RWMutex
+	entries map[string]time.Time
+}{entries: make(map[string]time.Time)}
+
+// RevokeToken revokes an access token by its ID until the token expires
+func RevokeToken(db *gorm.DB, jti string, userID uint, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+
+	revoked := models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}
+	if err := db.Where(models.RevokedToken{JTI: jti}).FirstOrCreate(&revoked).Error; err != nil {
+		return err
+	}
+
+	cacheRevocation(jti, expiresAt)
+	return nil
+}
+
+// IsTokenRevoked reports whether the token with the given ID was revoked
+func IsTokenRevoked(jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+
+	revocationCache.RLock()
+	expiresAt, ok := revocationCache.entries[jti]
+	revocationCache.RUnlock()
+	if ok {
+		if time.Now().Before(expiresAt) {
+			return true, nil
+		}
+		revocationCache.Lock()
+		delete(revocationCache.entries, jti)
+		revocationCache.Unlock()
+	}
+
+	// Another replica may have revoked the token, so fall back to the database
+	var revoked models.RevokedToken
+	err := database.GetDB().Where("jti = ?", jti).First(&revoked).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	cacheRevocation(revoked.JTI, revoked.ExpiresAt)
+	return true, nil
+}
+
+// InvalidateUserTokens rejects every access token issued to the user so far
+// and revokes all of their sessions and outstanding refresh tokens
+func InvalidateUserTokens(db *gorm.DB, userID uint) error {
+	now := time.Now()
+	if err := db.Model(&models.User{}).Where("id = ?", userID).Update("tokens_valid_after", now).Error; err != nil {
+		return err
+	}
+
+	if err := db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+
+	return db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+// ValidateClaims checks validated token claims against server-side state:
+// the revocation list, the user's active state and their "tokens issued
+// before" cut-off. It returns the user's current access-relevant fields.
+func ValidateClaims(claims *auth.Claims) (*models.User, error) {
+	revoked, err := IsTokenRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	var user models.User
+	if err := database.GetDB().
+		Select("id", "active", "suspended_until", "tokens_valid_after", "email_verified", "two_factor_enabled").
+		First(&user, claims.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	if !user.IsActive() {
+		return nil, ErrUserInactive
+	}
+
+	if claims.IssuedAt == nil {
+		return nil, ErrTokenRevoked
+	}
+
+	// Token timestamps only carry second precision, so the cut-off covers
+	// its whole second. A token from that second is only accepted when its
+	// session was started after the cut-off, by a login since.
+	cutoff := user.TokensValidAfter.Truncate(time.Second)
+	if !claims.IssuedAt.Time.After(cutoff) {
+		if claims.IssuedAt.Time.Before(cutoff) || claims.SessionID == 0 {
+			return nil, ErrTokenRevoked
+		}
+
+		var count int64
+		if err := database.GetDB().Model(&models.Session{}).
+			Where("id = ? AND user_id = ? AND created_at > ?", claims.SessionID, user.ID, user.TokensValidAfter).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrTokenRevoked
+		}
+	}
+
+	return &user, nil
+}
+
+// PurgeExpiredRevocations removes revocation records for tokens that have
+// expired anyway
+func PurgeExpiredRevocations(db *gorm.DB) error {
+	now := time.Now()
+
+	revocationCache.Lock()
+	for jti, expiresAt := range revocationCache.entries {
+		if now.After(expiresAt) {
+			delete(revocationCache.entries, jti)
+		}
+	}
+	revocationCache.Unlock()
+
+	return db.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error
+}
+
+func cacheRevocation(jti string, expiresAt time.Time) {
+	revocationCache.Lock()
+	revocationCache.entries[jti] = expiresAt
+	revocationCache.Unlock()
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"os"
+	"time"
+
+	"github.com/truncgil/gorecta/internal/models"
+	"gorm.io/gorm"
+)
+
+// postSchedulerLockID identifies the advisory lock that keeps replicas from
+// running the post scheduler at the same time
+const postSchedulerLockID int64 = 0x676f7265637461
+
+// PostSchedulerInterval returns how often due posts are published and
+// unpublished
+func PostSchedulerInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("POST_SCHEDULER_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Minute
+	}
+	return interval
+}
+
+// MigratePostStatuses sets the status of posts published before statuses
+// were introduced
+func MigratePostStatuses(db *gorm.DB) error {
+	return db.Model(&models.Post{}).
+		Where("published = ? AND status = ?", true, models.PostStatusDraft).
+		UpdateColumn("status", models.PostStatusPublished).Error
+}
+
+// RunPostSchedule publishes scheduled posts whose publish time has come and
+// archives published posts whose unpublish time has passed. When several
+// replicas run it at once, only the one holding the advisory lock does any
+// work; the others return zero counts.
+func RunPostSchedule(db *gorm.DB) (published, archived int64, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// The lock is released when the transaction ends
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", postSchedulerLockID).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		now := time.Now()
+
+		// Hooks are skipped, so Published is kept in line with the status here
+		result := tx.Model(&models.Post{}).
+			Where("status = ? AND publish_at <= ?", models.PostStatusScheduled, now).
+			UpdateColumns(map[string]interface{}{
+				"status":     models.PostStatusPublished,
+				"published":  true,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		published = result.RowsAffected
+
+		result = tx.Model(&models.Post{}).
+			Where("status = ? AND unpublish_at <= ?", models.PostStatusPublished, now).
+			UpdateColumns(map[string]interface{}{
+				"status":     models.PostStatusArchived,
+				"published":  false,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		archived = result.RowsAffected
+		return nil
+	})
+	return published, archived, err
+}
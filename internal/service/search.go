@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// searchLanguageSetting is the setting key holding the text search
+// configuration used to index and query posts
+const searchLanguageSetting = "search_language"
+
+// defaultSearchLanguage only lowercases words, which works for any language
+const defaultSearchLanguage = "simple"
+
+var ErrUnknownSearchLanguage = errors.New("unknown text search configuration")
+
+// searchLanguagePattern restricts configuration names to plain identifiers
+// since they are written into the generated column definition
+var searchLanguagePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// SearchLanguage returns the PostgreSQL text search configuration used for
+// post search, e.g. "english" or "turkish". The admin-managed setting takes
+// precedence over the SEARCH_LANGUAGE environment variable.
+func SearchLanguage() (string, error) {
+	def := os.Getenv("SEARCH_LANGUAGE")
+	if def == "" {
+		def = defaultSearchLanguage
+	}
+	return GetSetting(searchLanguageSetting, def)
+}
+
+// SearchLanguages lists the text search configurations the database knows
+func SearchLanguages(db *gorm.DB) ([]string, error) {
+	var languages []string
+	err := db.Raw("SELECT cfgname FROM pg_ts_config ORDER BY cfgname").Scan(&languages).Error
+	return languages, err
+}
+
+// SetSearchLanguage rebuilds the search index of posts with another text
+// search configuration and stores it. The setting only changes once the
+// index has been rebuilt.
+func SetSearchLanguage(db *gorm.DB, language string) error {
+	if err := checkSearchLanguage(db, language); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := buildSearchIndex(tx, language); err != nil {
+			return err
+		}
+		return SetSetting(tx, searchLanguageSetting, language)
+	})
+}
+
+// EnsureSearchIndex makes sure posts have a search_vector column generated
+// with the configured language and a GIN index over it
+func EnsureSearchIndex(db *gorm.DB) error {
+	language, err := SearchLanguage()
+	if err != nil {
+		return err
+	}
+	if err := checkSearchLanguage(db, language); err != nil {
+		return fmt.Errorf("search language %q: %w", language, err)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return buildSearchIndex(tx, language)
+	})
+}
+
+// buildSearchIndex generates the search_vector column of posts for the
+// language and indexes it. Titles are weighted above content so that title
+// matches rank first. The column is only rebuilt when it is missing or was
+// generated for another language.
+func buildSearchIndex(tx *gorm.DB, language string) error {
+	var expressions []string
+	if err := tx.Raw(`SELECT generation_expression FROM information_schema.columns
+		WHERE table_schema = CURRENT_SCHEMA() AND table_name = 'posts' AND column_name = 'search_vector'`).
+		Scan(&expressions).Error; err != nil {
+		return err
+	}
+	if len(expressions) > 0 && strings.Contains(expressions[0], "'"+language+"'::regconfig") {
+		return nil
+	}
+
+	statements := []string{
+		// Dropping the column drops its index too
+		"ALTER TABLE posts DROP COLUMN IF EXISTS search_vector",
+		fmt.Sprintf(`ALTER TABLE posts ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('%[1]s'::regconfig, COALESCE(title, '')), 'A') ||
+			setweight(to_tsvector('%[1]s'::regconfig, COALESCE(content, '')), 'B')
+		) STORED`, language),
+		"CREATE INDEX idx_posts_search_vector ON posts USING GIN (search_vector)",
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSearchLanguage verifies that language names an existing text search
+// configuration
+func checkSearchLanguage(db *gorm.DB, language string) error {
+	if !searchLanguagePattern.MatchString(language) {
+		return ErrUnknownSearchLanguage
+	}
+
+	var count int64
+	if err := db.Raw("SELECT COUNT(*) FROM pg_ts_config WHERE cfgname = ?", language).Scan(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUnknownSearchLanguage
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/pkg/auth"
+	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
+)
+
+// sessionActivityInterval limits how often last-seen tracking writes to the database
+const sessionActivityInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// maxUserAgentLength caps the stored user agent
+const maxUserAgentLength = 512
+
+// StartSession creates a session for a new login
+func StartSession(db *gorm.DB, userID uint, ip, userAgent string) (*models.Session, error) {
+	familyID, err := auth.NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:     userID,
+		FamilyID:   familyID,
+		UserAgent:  truncate(userAgent, maxUserAgentLength),
+		IP:         ip,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(auth.RefreshTokenTTL()),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ResumeSession returns the session a refresh token family belongs to and
+// records the activity. Families issued before sessions were tracked get a
+// session on their first refresh.
+func ResumeSession(db *gorm.DB, userID uint, familyID, ip, userAgent string) (*models.Session, error) {
+	now := time.Now()
+	userAgent = truncate(userAgent, maxUserAgentLength)
+
+	var session models.Session
+	err := db.Where("family_id = ?", familyID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		session = models.Session{
+			UserID:     userID,
+			FamilyID:   familyID,
+			UserAgent:  userAgent,
+			IP:         ip,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(auth.RefreshTokenTTL()),
+		}
+		return &session, db.Create(&session).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if session.UserID != userID || session.RevokedAt != nil {
+		return nil, ErrTokenRevoked
+	}
+
+	if err := db.Model(&session).UpdateColumns(map[string]interface{}{
+		"last_seen_at": now,
+		"ip":           ip,
+		"user_agent":   userAgent,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ValidateSession checks that the session a token belongs to has not been
+// revoked and records the activity. Tokens issued without a session are
+// accepted as they are.
+func ValidateSession(claims *auth.Claims, ip, userAgent string) error {
+	if claims.SessionID == 0 {
+		return nil
+	}
+
+	db := database.GetDB()
+
+	var session models.Session
+	if err := db.Select("id", "user_id", "ip", "user_agent", "last_seen_at", "revoked_at").
+		First(&session, claims.SessionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenRevoked
+		}
+		return err
+	}
+
+	if session.UserID != claims.UserID || session.RevokedAt != nil {
+		return ErrTokenRevoked
+	}
+
+	// Record activity, but not on every single request
+	userAgent = truncate(userAgent, maxUserAgentLength)
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) > sessionActivityInterval || session.IP != ip || session.UserAgent != userAgent {
+		if err := db.Model(&session).UpdateColumns(map[string]interface{}{
+			"last_seen_at": now,
+			"ip":           ip,
+			"user_agent":   userAgent,
+		}).Error; err != nil {
+			log.Printf("Failed to record session activity: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// RevokeSession revokes one of the user's sessions together with its
+// refresh tokens
+func RevokeSession(db *gorm.DB, userID, sessionID uint) error {
+	revoked, err := revokeSessions(db, "user_id = ? AND id = ?", userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions revokes all of the user's sessions except keepID and
+// returns how many were revoked. A keepID of 0 revokes every session.
+func RevokeOtherSessions(db *gorm.DB, userID, keepID uint) (int64, error) {
+	return revokeSessions(db, "user_id = ? AND id <> ?", userID, keepID)
+}
+
+// revokeSessions revokes the active sessions matching the condition and the
+// refresh tokens issued to them
+func revokeSessions(db *gorm.DB, query string, args ...interface{}) (int64, error) {
+	var revoked int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var familyIDs []string
+		if err := tx.Model(&models.Session{}).Where(query, args...).Where("revoked_at IS NULL").
+			Pluck("family_id", &familyIDs).Error; err != nil {
+			return err
+		}
+		if len(familyIDs) == 0 {
+			return nil
+		}
+
+		result := tx.Model(&models.Session{}).
+			Where("family_id IN ? AND revoked_at IS NULL", familyIDs).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		revoked = result.RowsAffected
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("family_id IN ? AND revoked_at IS NULL", familyIDs).
+			Update("revoked_at", now).Error
+	})
+	return revoked, err
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
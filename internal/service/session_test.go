@@ -0,0 +1,35 @@
+package service
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"Mozilla", 10, "Mozilla"},
+		{"Mozilla", 7, "Mozilla"},
+		{"Mozilla", 3, "Moz"},
+		{"çğş", 6, "çğş"},
+		{"çğş", 5, "çğ"},
+		{"çğş", 3, "ç"},
+		{"a€b", 3, "a"},
+		{"€", 2, ""},
+		{"😀x", 3, ""},
+		{"😀x", 4, "😀"},
+	}
+
+	for _, tt := range tests {
+		got := truncate(tt.s, tt.n)
+		if got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) = %q is not valid UTF-8", tt.s, tt.n, got)
+		}
+	}
+}
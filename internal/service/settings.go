@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/truncgil/gorecta/internal/models"
+	"github.com/truncgil/gorecta/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// settingsCacheTTL bounds how long other replicas may serve a stale setting
+const settingsCacheTTL = time.Minute
+
+type cachedSetting struct {
+	value    string
+	found    bool
+	loadedAt time.Time
+}
+
+var settingsCache = struct {
+	sync.RWMutex
+	entries map[string]cachedSetting
+}{entries: make(map[string]cachedSetting)}
+
+// GetSetting returns the stored value for key, or def if it is not set
+func GetSetting(key, def string) (string, error) {
+	settingsCache.RLock()
+	cached, ok := settingsCache.entries[key]
+	settingsCache.RUnlock()
+	if ok && time.Since(cached.loadedAt) < settingsCacheTTL {
+		if cached.found {
+			return cached.value, nil
+		}
+		return def, nil
+	}
+
+	var setting models.Setting
+	err := database.GetDB().Where("key = ?", key).First(&setting).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return def, err
+	}
+	found := err == nil
+
+	settingsCache.Lock()
+	settingsCache.entries[key] = cachedSetting{value: setting.Value, found: found, loadedAt: time.Now()}
+	settingsCache.Unlock()
+
+	if !found {
+		return def, nil
+	}
+	return setting.Value, nil
+}
+
+// SetSetting stores value for key
+func SetSetting(db *gorm.DB, key, value string) error {
+	setting := models.Setting{Key: key, Value: value}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&setting).Error; err != nil {
+		return err
+	}
+
+	settingsCache.Lock()
+	settingsCache.entries[key] = cachedSetting{value: value, found: true, loadedAt: time.Now()}
+	settingsCache.Unlock()
+	return nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/truncgil/gorecta/internal/models"
+	"gorm.io/gorm"
+)
+
+// SlugTaken reports whether an item other than excludeID uses the slug now
+// or used it before. Slugs are unique per resource type, whose name is also
+// its table, e.g. "posts"; slugs of items in the trash and former slugs kept
+// for redirects count as taken.
+func SlugTaken(db *gorm.DB, resourceType, slug string, excludeID uint) (bool, error) {
+	var count int64
+	if err := db.Table(resourceType).Where("slug = ? AND id <> ?", slug, excludeID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	if err := db.Model(&models.SlugHistory{}).
+		Where("resource_type = ? AND slug = ? AND resource_id <> ?", resourceType, slug, excludeID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// UniqueSlug returns base if it is free for the item excludeID, and
+// otherwise base with the lowest free numeric suffix, e.g. "hello-2"
+func UniqueSlug(db *gorm.DB, resourceType, base string, excludeID uint) (string, error) {
+	var current, former []string
+	if err := db.Table(resourceType).
+		Where("id <> ? AND (slug = ? OR slug LIKE ?)", excludeID, base, base+"-%").
+		Pluck("slug", &current).Error; err != nil {
+		return "", err
+	}
+	if err := db.Model(&models.SlugHistory{}).
+		Where("resource_type = ? AND resource_id <> ? AND (slug = ? OR slug LIKE ?)", resourceType, excludeID, base, base+"-%").
+		Pluck("slug", &former).Error; err != nil {
+		return "", err
+	}
+
+	taken := make(map[string]bool, len(current)+len(former))
+	for _, slug := range append(current, former...) {
+		taken[slug] = true
+	}
+
+	slug := base
+	for n := 2; taken[slug]; n++ {
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+	return slug, nil
+}
+
+// RecordSlugChange keeps the former slug of an item so that it redirects to
+// the new one. Taking back a former slug removes it from the history.
+func RecordSlugChange(tx *gorm.DB, resourceType string, id uint, from, to string) error {
+	if from == to || from == "" {
+		return nil
+	}
+
+	if err := tx.Where("resource_type = ? AND resource_id = ? AND slug = ?", resourceType, id, to).
+		Delete(&models.SlugHistory{}).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.SlugHistory{ResourceType: resourceType, ResourceID: id, Slug: from}).Error
+}
+
+// FindFormerSlug returns the history entry of a former slug, or
+// gorm.ErrRecordNotFound if no item used it
+func FindFormerSlug(db *gorm.DB, resourceType, slug string) (*models.SlugHistory, error) {
+	var history models.SlugHistory
+	if err := db.Where("resource_type = ? AND slug = ?", resourceType, slug).First(&history).Error; err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// deleteSlugHistory removes the former slugs of purged items
+func deleteSlugHistory(tx *gorm.DB, resourceType string, ids []uint) error {
+	return tx.Where("resource_type = ? AND resource_id IN ?", resourceType, ids).Delete(&models.SlugHistory{}).Error
+}
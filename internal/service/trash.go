@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/truncgil/gorecta/internal/models"
+	"gorm.io/gorm"
+)
+
+// defaultTrashRetention is how long trashed content is kept unless
+// TRASH_RETENTION says otherwise
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// trashPurgeLockID identifies the advisory lock that keeps replicas from
+// purging the trash at the same time
+const trashPurgeLockID int64 = postSchedulerLockID + 1
+
+var ErrCategoryInUse = errors.New("category is used by posts")
+
+// TrashRetention returns how long content stays in the trash before it is
+// purged. Zero keeps it until it is purged by hand.
+func TrashRetention() time.Duration {
+	retention, err := time.ParseDuration(os.Getenv("TRASH_RETENTION"))
+	if err != nil || retention < 0 {
+		return defaultTrashRetention
+	}
+	return retention
+}
+
+// PurgePosts permanently deletes posts together with their tag links,
+// revisions, workflow history and former slugs
+func PurgePosts(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := tx.Exec("DELETE FROM post_tags WHERE post_id IN ?", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("post_id IN ?", ids).Delete(&models.PostRevision{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("post_id IN ?", ids).Delete(&models.PostTransition{}).Error; err != nil {
+		return err
+	}
+	if err := deleteSlugHistory(tx, "posts", ids); err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&models.Post{}, ids).Error
+}
+
+// PurgeTags permanently deletes tags and unlinks them from their posts
+func PurgeTags(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := tx.Exec("DELETE FROM post_tags WHERE tag_id IN ?", ids).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&models.Tag{}, ids).Error
+}
+
+// PurgeCategory permanently deletes a category. It returns ErrCategoryInUse
+// while posts, including posts in the trash, still belong to the category.
+func PurgeCategory(tx *gorm.DB, id uint) error {
+	var count int64
+	if err := tx.Unscoped().Model(&models.Post{}).Where("category_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCategoryInUse
+	}
+	return purgeCategories(tx, []uint{id})
+}
+
+func purgeCategories(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := deleteSlugHistory(tx, "categories", ids); err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&models.Category{}, ids).Error
+}
+
+// PurgeTrash permanently deletes posts, categories and tags that have been
+// in the trash for longer than TrashRetention. Categories are kept until the
+// posts that belong to them have been purged. When several replicas run it at
+// once, only the one holding the advisory lock does any work; the others
+// return zero counts.
+func PurgeTrash(db *gorm.DB) (posts, categories, tags int64, err error) {
+	retention := TrashRetention()
+	if retention == 0 {
+		return 0, 0, 0, nil
+	}
+	cutoff := time.Now().Add(-retention)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// The lock is released when the transaction ends
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", trashPurgeLockID).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		var postIDs []uint
+		if err := tx.Unscoped().Model(&models.Post{}).Where("deleted_at < ?", cutoff).Pluck("id", &postIDs).Error; err != nil {
+			return err
+		}
+		if err := PurgePosts(tx, postIDs); err != nil {
+			return err
+		}
+
+		var tagIDs []uint
+		if err := tx.Unscoped().Model(&models.Tag{}).Where("deleted_at < ?", cutoff).Pluck("id", &tagIDs).Error; err != nil {
+			return err
+		}
+		if err := PurgeTags(tx, tagIDs); err != nil {
+			return err
+		}
+
+		var categoryIDs []uint
+		if err := tx.Unscoped().Model(&models.Category{}).
+			Where("deleted_at < ?", cutoff).
+			Where("NOT EXISTS (SELECT 1 FROM posts WHERE posts.category_id = categories.id)").
+			Pluck("id", &categoryIDs).Error; err != nil {
+			return err
+		}
+		if err := purgeCategories(tx, categoryIDs); err != nil {
+			return err
+		}
+
+		posts, categories, tags = int64(len(postIDs)), int64(len(categoryIDs)), int64(len(tagIDs))
+		return nil
+	})
+	return posts, categories, tags, err
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"os"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// twoFactorRolesSetting is the setting key holding the roles that must use 2FA
+const twoFactorRolesSetting = "two_factor_required_roles"
+
+// TwoFactorRequiredRoles returns the roles that must enable two-factor
+// authentication. The admin-managed setting takes precedence over the
+// TWO_FACTOR_REQUIRED_ROLES environment variable.
+func TwoFactorRequiredRoles() ([]string, error) {
+	value, err := GetSetting(twoFactorRolesSetting, os.Getenv("TWO_FACTOR_REQUIRED_ROLES"))
+	if err != nil {
+		return nil, err
+	}
+	return splitList(value), nil
+}
+
+// SetTwoFactorRequiredRoles replaces the roles that must use two-factor
+// authentication
+func SetTwoFactorRequiredRoles(db *gorm.DB, roles []string) error {
+	return SetSetting(db, twoFactorRolesSetting, strings.Join(roles, ","))
+}
+
+// RoleRequiresTwoFactor reports whether users with the role must use
+// two-factor authentication
+func RoleRequiresTwoFactor(role string) (bool, error) {
+	roles, err := TwoFactorRequiredRoles()
+	if err != nil {
+		return false, err
+	}
+	for _, r := range roles {
+		if r == role {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
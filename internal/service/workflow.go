@@ -0,0 +1,134 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/truncgil/gorecta/internal/models"
+	"gorm.io/gorm"
+)
+
+// workflowSetting is the setting key holding the post workflow as JSON
+const workflowSetting = "post_workflow"
+
+var ErrInvalidWorkflow = errors.New("invalid workflow")
+
+var workflowNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// WorkflowTransition moves a post from one of the From statuses to To.
+// Moving to published with a future publish time schedules the post instead.
+type WorkflowTransition struct {
+	Name string   `json:"name"`
+	From []string `json:"from"`
+	To   string   `json:"to"`
+	// Action names the permission needed: posts.<action> for any post, or
+	// posts.<action>_own for the post's author
+	Action          string `json:"action"`
+	CommentRequired bool   `json:"comment_required"`
+	// NotifyAuthor emails the post's author when the transition is made
+	NotifyAuthor bool `json:"notify_author"`
+}
+
+// Allows reports whether the transition can be made from the status
+func (t WorkflowTransition) Allows(status string) bool {
+	for _, from := range t.From {
+		if from == status {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultWorkflow takes posts from draft through review to publication
+var DefaultWorkflow = []WorkflowTransition{
+	{Name: "submit", From: []string{models.PostStatusDraft}, To: models.PostStatusInReview, Action: "submit"},
+	{Name: "withdraw", From: []string{models.PostStatusInReview}, To: models.PostStatusDraft, Action: "submit"},
+	{Name: "approve", From: []string{models.PostStatusInReview}, To: models.PostStatusApproved, Action: "review"},
+	{Name: "send_back", From: []string{models.PostStatusInReview, models.PostStatusApproved}, To: models.PostStatusDraft,
+		Action: "review", CommentRequired: true, NotifyAuthor: true},
+	{Name: "publish", From: []string{models.PostStatusApproved, models.PostStatusScheduled}, To: models.PostStatusPublished, Action: "publish"},
+	{Name: "unschedule", From: []string{models.PostStatusScheduled}, To: models.PostStatusApproved, Action: "publish"},
+	{Name: "archive", From: []string{models.PostStatusPublished}, To: models.PostStatusArchived, Action: "publish"},
+	{Name: "reopen", From: []string{models.PostStatusArchived}, To: models.PostStatusDraft, Action: "publish"},
+}
+
+// Workflow returns the transitions posts can go through. The admin-managed
+// setting takes precedence over DefaultWorkflow.
+func Workflow() ([]WorkflowTransition, error) {
+	value, err := GetSetting(workflowSetting, "")
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return DefaultWorkflow, nil
+	}
+
+	var transitions []WorkflowTransition
+	if err := json.Unmarshal([]byte(value), &transitions); err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
+
+// SetWorkflow validates and stores the transitions posts can go through
+func SetWorkflow(db *gorm.DB, transitions []WorkflowTransition) error {
+	if err := ValidateWorkflow(transitions); err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(transitions)
+	if err != nil {
+		return err
+	}
+	return SetSetting(db, workflowSetting, string(value))
+}
+
+// ValidateWorkflow checks that transitions have unique names, use known
+// statuses and refer to existing permissions. Errors wrap ErrInvalidWorkflow.
+func ValidateWorkflow(transitions []WorkflowTransition) error {
+	if len(transitions) == 0 {
+		return fmt.Errorf("%w: at least one transition is required", ErrInvalidWorkflow)
+	}
+
+	permissions := make(map[string]bool, len(DefaultPermissions))
+	for _, p := range DefaultPermissions {
+		permissions[p.Name] = true
+	}
+
+	names := make(map[string]bool, len(transitions))
+	for _, t := range transitions {
+		if !workflowNamePattern.MatchString(t.Name) {
+			return fmt.Errorf("%w: invalid transition name %q", ErrInvalidWorkflow, t.Name)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("%w: duplicate transition %q", ErrInvalidWorkflow, t.Name)
+		}
+		names[t.Name] = true
+
+		if len(t.From) == 0 {
+			return fmt.Errorf("%w: transition %q has no from statuses", ErrInvalidWorkflow, t.Name)
+		}
+		for _, status := range append([]string{t.To}, t.From...) {
+			if !models.IsPostStatus(status) {
+				return fmt.Errorf("%w: transition %q uses unknown status %q", ErrInvalidWorkflow, t.Name, status)
+			}
+		}
+
+		if !permissions["posts."+t.Action] && !permissions["posts."+t.Action+"_own"] {
+			return fmt.Errorf("%w: transition %q uses unknown action %q", ErrInvalidWorkflow, t.Name, t.Action)
+		}
+	}
+	return nil
+}
+
+// FindTransition returns the named transition of the workflow
+func FindTransition(workflow []WorkflowTransition, name string) (WorkflowTransition, bool) {
+	for _, t := range workflow {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return WorkflowTransition{}, false
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+)
+
+// APIKeyPrefix starts every API key so keys are recognisable in headers,
+// logs and secret scanners
+const APIKeyPrefix = "grk_"
+
+// apiKeyIDLength is the length of the public identifier part of a key
+const apiKeyIDLength = 8
+
+// GenerateAPIKey returns a new API key of the form grk_<id>_<secret>, its
+// public identifier and the hash to store
+func GenerateAPIKey() (key, id, hash string, err error) {
+	idBytes := make([]byte, 6)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+
+	id = base64.RawURLEncoding.EncodeToString(idBytes)
+	key = APIKeyPrefix + id + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	return key, id, HashToken(key), nil
+}
+
+// IsAPIKey reports whether the bearer credential looks like an API key
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// APIKeyID extracts the public identifier from an API key
+func APIKeyID(key string) (string, bool) {
+	if !IsAPIKey(key) {
+		return "", false
+	}
+	rest := key[len(APIKeyPrefix):]
+	if len(rest) <= apiKeyIDLength+1 || rest[apiKeyIDLength] != '_' {
+		return "", false
+	}
+	return rest[:apiKeyIDLength], true
+}
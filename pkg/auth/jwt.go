@@ -2,40 +2,102 @@ package auth
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// PurposeTwoFactor marks a challenge token issued after the password step
+// of a two-factor login
+const PurposeTwoFactor = "2fa"
+
+// challengeTokenTTL is the lifetime of two-factor login challenge tokens
+const challengeTokenTTL = 5 * time.Minute
+
 // Claims represents the JWT claims
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID  uint   `json:"user_id"`
+	Email   string `json:"email"`
+	Role    string `json:"role"`
+	Purpose string `json:"purpose,omitempty"`
+	// SessionID is the login session the token belongs to
+	SessionID uint `json:"sid,omitempty"`
+	// ActorID is the admin acting as the user in an impersonation token
+	ActorID uint `json:"actor_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a new JWT token
-func GenerateToken(userID uint, email, role string) (string, error) {
-	// Get JWT expiration time from env
-	expirationTime, err := time.ParseDuration(os.Getenv("JWT_EXPIRATION"))
+// GenerateToken generates a new JWT token for a login session
+func GenerateToken(userID uint, email, role string, sessionID uint) (string, error) {
+	return generateToken(&Claims{UserID: userID, Email: email, Role: role, SessionID: sessionID}, AccessTokenTTL())
+}
+
+// GenerateChallengeToken generates a short-lived token proving that the user
+// passed the password step of a two-factor login. It cannot be used as an
+// access token.
+func GenerateChallengeToken(userID uint, email, role string) (string, error) {
+	return generateToken(&Claims{UserID: userID, Email: email, Role: role, Purpose: PurposeTwoFactor}, challengeTokenTTL)
+}
+
+// GenerateImpersonationToken generates a short-lived access token that lets
+// the actor act as the user. It is not tied to a session and cannot be
+// refreshed.
+func GenerateImpersonationToken(userID uint, email, role string, actorID uint) (string, error) {
+	return generateToken(&Claims{UserID: userID, Email: email, Role: role, ActorID: actorID}, ImpersonationTokenTTL())
+}
+
+// IsImpersonation reports whether the token was issued for impersonation
+func (c *Claims) IsImpersonation() bool {
+	return c.ActorID != 0
+}
+
+// ValidateToken validates the JWT token
+func ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens issued for a specific purpose are not access tokens
+	if claims.Purpose != "" {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	return claims, nil
+}
+
+// ValidateChallengeToken validates a two-factor login challenge token
+func ValidateChallengeToken(tokenString string) (*Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != PurposeTwoFactor {
+		return nil, fmt.Errorf("invalid challenge token")
+	}
+
+	return claims, nil
+}
+
+func generateToken(claims *Claims, expirationTime time.Duration) (string, error) {
+	tokenID, err := NewTokenID()
 	if err != nil {
-		expirationTime = 24 * time.Hour // default to 24 hours
+		return "", err
 	}
 
-	claims := &Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expirationTime)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        tokenID,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expirationTime)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	ks, err := getKeySet()
+	if err != nil {
+		return "", err
+	}
+
+	tokenString, err := ks.sign(claims)
 	if err != nil {
 		return "", err
 	}
@@ -43,15 +105,13 @@ func GenerateToken(userID uint, email, role string) (string, error) {
 	return tokenString, nil
 }
 
-// ValidateToken validates the JWT token
-func ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+func parseToken(tokenString string) (*Claims, error) {
+	ks, err := getKeySet()
+	if err != nil {
+		return nil, err
+	}
 
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, ks.keyFunc, jwt.WithValidMethods(ks.validMethods()))
 	if err != nil {
 		return nil, err
 	}
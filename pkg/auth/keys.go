@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// verificationKey is a public key that tokens may be signed with
+type verificationKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    crypto.PublicKey
+}
+
+// keySet holds the key used to sign new tokens and every key accepted when
+// verifying tokens
+type keySet struct {
+	method     jwt.SigningMethod
+	signingKey interface{}
+	signingKid string
+	hmacSecret []byte
+	verifyKeys map[string]verificationKey
+}
+
+var (
+	keysMu  sync.RWMutex
+	keysSet *keySet
+)
+
+// LoadKeys loads signing and verification keys from the environment:
+//
+//   - JWT_SIGNING_ALG selects HS256 (default), RS256 or EdDSA
+//   - JWT_PRIVATE_KEY_FILE is the PEM private key used for RS256/EdDSA signing
+//   - JWT_PUBLIC_KEY_FILES is a comma-separated list of additional PEM public
+//     keys that are accepted during key rotation
+//   - JWT_SECRET signs HS256 tokens and, when set alongside an asymmetric
+//     algorithm, keeps previously issued HS256 tokens valid
+func LoadKeys() error {
+	ks, err := loadKeySet()
+	if err != nil {
+		return err
+	}
+
+	keysMu.Lock()
+	keysSet = ks
+	keysMu.Unlock()
+	return nil
+}
+
+// getKeySet returns the loaded key set, loading it on first use
+func getKeySet() (*keySet, error) {
+	keysMu.RLock()
+	ks := keysSet
+	keysMu.RUnlock()
+	if ks != nil {
+		return ks, nil
+	}
+
+	if err := LoadKeys(); err != nil {
+		return nil, err
+	}
+
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return keysSet, nil
+}
+
+func loadKeySet() (*keySet, error) {
+	ks := &keySet{
+		hmacSecret: []byte(os.Getenv("JWT_SECRET")),
+		verifyKeys: make(map[string]verificationKey),
+	}
+
+	switch strings.ToUpper(os.Getenv("JWT_SIGNING_ALG")) {
+	case "", "HS256":
+		ks.method = jwt.SigningMethodHS256
+		ks.signingKey = ks.hmacSecret
+	case "RS256":
+		ks.method = jwt.SigningMethodRS256
+	case "EDDSA":
+		ks.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported JWT signing algorithm: %s", os.Getenv("JWT_SIGNING_ALG"))
+	}
+
+	if ks.method != jwt.SigningMethodHS256 {
+		path := os.Getenv("JWT_PRIVATE_KEY_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", ks.method.Alg())
+		}
+
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT private key: %v", err)
+		}
+
+		var public crypto.PublicKey
+		if ks.method == jwt.SigningMethodRS256 {
+			key, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse RSA private key: %v", err)
+			}
+			ks.signingKey, public = key, &key.PublicKey
+		} else {
+			key, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse Ed25519 private key: %v", err)
+			}
+			edKey, ok := key.(ed25519.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("JWT private key is not an Ed25519 key")
+			}
+			ks.signingKey, public = edKey, edKey.Public()
+		}
+
+		vk, err := newVerificationKey(public)
+		if err != nil {
+			return nil, err
+		}
+		ks.signingKid = vk.kid
+		ks.verifyKeys[vk.kid] = vk
+	}
+
+	for _, path := range strings.Split(os.Getenv("JWT_PUBLIC_KEY_FILES"), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT public key %s: %v", path, err)
+		}
+
+		public, err := parsePublicKeyPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT public key %s: %v", path, err)
+		}
+
+		vk, err := newVerificationKey(public)
+		if err != nil {
+			return nil, err
+		}
+		ks.verifyKeys[vk.kid] = vk
+	}
+
+	if ks.method == jwt.SigningMethodHS256 && len(ks.hmacSecret) == 0 {
+		return nil, fmt.Errorf("JWT_SECRET is required for HS256")
+	}
+
+	return ks, nil
+}
+
+// sign signs the claims with the current signing key
+func (ks *keySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.method, claims)
+	if ks.signingKid != "" {
+		token.Header["kid"] = ks.signingKid
+	}
+	return token.SignedString(ks.signingKey)
+}
+
+// keyFunc selects the verification key for a token by its algorithm and kid
+func (ks *keySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if len(ks.hmacSecret) == 0 {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return ks.hmacSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	vk, ok := ks.verifyKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	if vk.method.Alg() != token.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return vk.key, nil
+}
+
+// validMethods lists the algorithms accepted when parsing tokens
+func (ks *keySet) validMethods() []string {
+	methods := []string{}
+	if len(ks.hmacSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	seen := map[string]bool{}
+	for _, vk := range ks.verifyKeys {
+		if alg := vk.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set
+func JWKS() (map[string]interface{}, error) {
+	ks, err := getKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []map[string]string{}
+	for _, vk := range ks.verifyKeys {
+		jwk := publicJWK(vk.key)
+		jwk["kid"] = vk.kid
+		jwk["use"] = "sig"
+		jwk["alg"] = vk.method.Alg()
+		keys = append(keys, jwk)
+	}
+
+	return map[string]interface{}{"keys": keys}, nil
+}
+
+func newVerificationKey(public crypto.PublicKey) (verificationKey, error) {
+	var method jwt.SigningMethod
+	switch public.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported public key type %T", public)
+	}
+
+	kid, err := thumbprint(public)
+	if err != nil {
+		return verificationKey{}, err
+	}
+
+	return verificationKey{kid: kid, method: method, key: public}, nil
+}
+
+func parsePublicKeyPEM(pemBytes []byte) (crypto.PublicKey, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes); err == nil {
+		return key, nil
+	}
+	return jwt.ParseEdPublicKeyFromPEM(pemBytes)
+}
+
+// publicJWK returns the public members of the key as a JWK
+func publicJWK(public crypto.PublicKey) map[string]string {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(key),
+		}
+	}
+	return map[string]string{}
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint used as the key ID
+func thumbprint(public crypto.PublicKey) (string, error) {
+	jwk := publicJWK(public)
+
+	// Required members only, in lexicographic order
+	var members []string
+	switch jwk["kty"] {
+	case "RSA":
+		members = []string{"e", "kty", "n"}
+	case "OKP":
+		members = []string{"crv", "kty", "x"}
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", public)
+	}
+
+	parts := make([]string, len(members))
+	for i, m := range members {
+		value, _ := json.Marshal(jwk[m])
+		parts[i] = fmt.Sprintf("%q:%s", m, value)
+	}
+
+	sum := sha256.Sum256([]byte("{" + strings.Join(parts, ",") + "}"))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePEM writes a private key in PKCS#8 form, or a public key in PKIX
+// form, and returns the file path
+func writePEM(t *testing.T, name string, key interface{}) string {
+	t.Helper()
+
+	var block *pem.Block
+	switch key.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// useKeys configures the signing keys for the test and loads them
+func useKeys(t *testing.T, alg, privateKeyFile, publicKeyFiles, secret string) error {
+	t.Helper()
+	t.Setenv("JWT_SIGNING_ALG", alg)
+	t.Setenv("JWT_PRIVATE_KEY_FILE", privateKeyFile)
+	t.Setenv("JWT_PUBLIC_KEY_FILES", publicKeyFiles)
+	t.Setenv("JWT_SECRET", secret)
+	return LoadKeys()
+}
+
+func TestSignAndValidate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		alg     string
+		key     interface{}
+		wantAlg string
+	}{
+		{"HS256", "", nil, "HS256"},
+		{"RS256", "RS256", rsaKey, "RS256"},
+		{"EdDSA", "EdDSA", edKey, "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keyFile string
+			if tt.key != nil {
+				keyFile = writePEM(t, "private.pem", tt.key)
+			}
+			if err := useKeys(t, tt.alg, keyFile, "", "test-secret"); err != nil {
+				t.Fatalf("LoadKeys: %v", err)
+			}
+
+			token, err := GenerateToken(7, "jane@example.com", "author", 3)
+			if err != nil {
+				t.Fatalf("GenerateToken: %v", err)
+			}
+			claims, err := ValidateToken(token)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+			if claims.UserID != 7 || claims.SessionID != 3 || claims.Role != "author" {
+				t.Errorf("claims = %+v", claims)
+			}
+
+			ks, err := getKeySet()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ks.method.Alg(); got != tt.wantAlg {
+				t.Errorf("signed with %s, want %s", got, tt.wantAlg)
+			}
+		})
+	}
+}
+
+func TestLoadKeysErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		alg            string
+		privateKeyFile string
+		secret         string
+	}{
+		{"unknown algorithm", "ES256", "", "test-secret"},
+		{"HS256 without secret", "HS256", "", ""},
+		{"RS256 without key", "RS256", "", "test-secret"},
+		{"missing key file", "RS256", filepath.Join(t.TempDir(), "missing.pem"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := useKeys(t, tt.alg, tt.privateKeyFile, "", tt.secret); err == nil {
+				t.Error("LoadKeys succeeded, want an error")
+			}
+		})
+	}
+}
+
+// Tokens signed with a retired key stay valid while its public key is listed
+func TestKeyRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := useKeys(t, "HS256", "", "", "test-secret"); err != nil {
+		t.Fatal(err)
+	}
+	hsToken, err := GenerateToken(1, "jane@example.com", "author", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := useKeys(t, "RS256", writePEM(t, "old.pem", oldKey), "", ""); err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := GenerateToken(1, "jane@example.com", "author", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldPublic := writePEM(t, "old.pub.pem", &oldKey.PublicKey)
+	if err := useKeys(t, "EdDSA", writePEM(t, "new.pem", newKey), oldPublic, "test-secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(oldToken); err != nil {
+		t.Errorf("token signed with the old key was rejected: %v", err)
+	}
+	if _, err := ValidateToken(hsToken); err != nil {
+		t.Errorf("HS256 token was rejected while JWT_SECRET is set: %v", err)
+	}
+
+	jwks, err := JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := jwks["keys"].([]map[string]string); len(keys) != 2 {
+		t.Errorf("JWKS lists %d keys, want 2", len(keys))
+	}
+
+	// Dropping the old public key and the secret retires their tokens
+	if err := useKeys(t, "EdDSA", writePEM(t, "new.pem", newKey), "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(oldToken); err == nil {
+		t.Error("token signed with a retired key was accepted")
+	}
+	if _, err := ValidateToken(hsToken); err == nil {
+		t.Error("HS256 token was accepted without JWT_SECRET")
+	}
+}
+
+func TestChallengeTokenIsNotAnAccessToken(t *testing.T) {
+	if err := useKeys(t, "HS256", "", "", "test-secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := GenerateChallengeToken(1, "jane@example.com", "author")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(challenge); err == nil {
+		t.Error("challenge token was accepted as an access token")
+	}
+	if _, err := ValidateChallengeToken(challenge); err != nil {
+		t.Errorf("ValidateChallengeToken: %v", err)
+	}
+
+	access, err := GenerateToken(1, "jane@example.com", "author", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateChallengeToken(access); err == nil {
+		t.Error("access token was accepted as a challenge token")
+	}
+}
+
+// The example key of RFC 7638, section 3.1
+func TestThumbprint(t *testing.T) {
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+
+	got, err := thumbprint(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+		t.Errorf("thumbprint = %q, want %q", got, want)
+	}
+}
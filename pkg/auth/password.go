@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptMaxLength is the number of bytes bcrypt actually hashes; anything
+// after it is silently ignored
+const bcryptMaxLength = 72
+
+// PasswordPolicy describes which passwords are accepted
+type PasswordPolicy struct {
+	MinLength       int
+	MaxLength       int
+	RequiredClasses []string
+	// DisallowPersonal rejects passwords containing the user's email or name
+	DisallowPersonal bool
+	// BreachDir holds a breached password list in the k-anonymity range
+	// format: one file per 5-character SHA-1 prefix, named after the prefix,
+	// with a "SUFFIX:COUNT" line per password
+	BreachDir      string
+	BreachMinCount int
+}
+
+// PasswordPolicyError lists every rule a password breaks
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Violations, "; ")
+}
+
+// characterClasses are the classes PASSWORD_REQUIRED_CLASSES can list
+var characterClasses = map[string]struct {
+	description string
+	matches     func(rune) bool
+}{
+	"lower":  {"a lowercase letter", unicode.IsLower},
+	"upper":  {"an uppercase letter", unicode.IsUpper},
+	"digit":  {"a digit", unicode.IsDigit},
+	"symbol": {"a symbol", func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) }},
+}
+
+// LoadPasswordPolicy reads the password policy from the environment
+func LoadPasswordPolicy() PasswordPolicy {
+	policy := PasswordPolicy{
+		MinLength:        envInt("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:        envInt("PASSWORD_MAX_LENGTH", bcryptMaxLength),
+		DisallowPersonal: os.Getenv("PASSWORD_DISALLOW_PERSONAL_INFO") != "false",
+		BreachDir:        os.Getenv("PASSWORD_BREACH_DIR"),
+		BreachMinCount:   envInt("PASSWORD_BREACH_MIN_COUNT", 1),
+	}
+
+	if policy.MaxLength <= 0 || policy.MaxLength > bcryptMaxLength {
+		policy.MaxLength = bcryptMaxLength
+	}
+
+	for _, class := range strings.Split(os.Getenv("PASSWORD_REQUIRED_CLASSES"), ",") {
+		class = strings.ToLower(strings.TrimSpace(class))
+		if _, ok := characterClasses[class]; ok {
+			policy.RequiredClasses = append(policy.RequiredClasses, class)
+		}
+	}
+
+	return policy
+}
+
+// ValidatePassword checks a new password against the configured policy
+func ValidatePassword(password, email, name string) error {
+	return LoadPasswordPolicy().Validate(password, email, name)
+}
+
+// Validate checks a new password for the user with the given email and
+// name. It returns a *PasswordPolicyError listing the broken rules.
+func (p PasswordPolicy) Validate(password, email, name string) error {
+	var violations []string
+
+	if n := len([]rune(password)); n < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if len(password) > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes", p.MaxLength))
+	}
+
+	for _, class := range p.RequiredClasses {
+		if strings.IndexFunc(password, characterClasses[class].matches) < 0 {
+			violations = append(violations, "must contain "+characterClasses[class].description)
+		}
+	}
+
+	if p.DisallowPersonal && containsPersonalInfo(password, email, name) {
+		violations = append(violations, "must not contain your email or name")
+	}
+
+	if p.BreachDir != "" {
+		breached, err := p.isBreached(password)
+		if err != nil {
+			// An unreadable list must not lock everyone out of changing passwords
+			log.Printf("Failed to check breached password list: %v", err)
+		} else if breached {
+			violations = append(violations, "has appeared in a data breach")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsPersonalInfo reports whether the password contains the email, its
+// local part or any word of the name that is at least 3 characters long
+func containsPersonalInfo(password, email, name string) bool {
+	password = strings.ToLower(password)
+
+	email = strings.ToLower(email)
+	local, _, _ := strings.Cut(email, "@")
+	parts := append([]string{email, local}, strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})...)
+
+	for _, part := range parts {
+		if len([]rune(part)) >= 3 && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBreached looks the password up in the breached password list. Only the
+// file for the first 5 characters of its SHA-1 hash is read.
+func (p PasswordPolicy) isBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	var file *os.File
+	for _, name := range []string{prefix, prefix + ".txt", strings.ToLower(prefix), strings.ToLower(prefix) + ".txt"} {
+		f, err := os.Open(filepath.Join(p.BreachDir, name))
+		if err == nil {
+			file = f
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return false, err
+		}
+	}
+	if file == nil {
+		// No file for the prefix means no breached password has it
+		return false, nil
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineSuffix, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !strings.EqualFold(lineSuffix, suffix) {
+			continue
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil {
+			// Lists without counts only contain breached passwords
+			return true, nil
+		}
+		return n >= p.BreachMinCount, nil
+	}
+	return false, scanner.Err()
+}
+
+// PasswordHashCost returns the bcrypt cost used for new password hashes
+func PasswordHashCost() int {
+	cost := envInt("BCRYPT_COST", bcrypt.DefaultCost)
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+func envInt(key string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:        8,
+		MaxLength:        bcryptMaxLength,
+		RequiredClasses:  []string{"lower", "upper", "digit"},
+		DisallowPersonal: true,
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"valid", "Correct7Horse", nil},
+		{"too short", "Ab1", []string{"must be at least 8 characters"}},
+		{"multibyte characters count once", "çğüşiöı1", []string{"must contain an uppercase letter"}},
+		{"too long", "Aa1" + strings.Repeat("x", bcryptMaxLength), []string{"must be at most 72 bytes"}},
+		{"missing classes", "lowercaseonly", []string{"must contain an uppercase letter", "must contain a digit"}},
+		{"contains email local part", "XjaneDoe99", []string{"must not contain your email or name"}},
+		{"contains a name word", "Pass1Smithers", []string{"must not contain your email or name"}},
+		{
+			name:     "every violation",
+			password: "jane",
+			want: []string{
+				"must be at least 8 characters",
+				"must contain an uppercase letter",
+				"must contain a digit",
+				"must not contain your email or name",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, "janedoe@example.com", "Jane Smithers")
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Validate = %v, want nil", err)
+				}
+				return
+			}
+
+			var policyErr *PasswordPolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Validate = %v, want a *PasswordPolicyError", err)
+			}
+			if !reflect.DeepEqual(policyErr.Violations, tt.want) {
+				t.Errorf("violations = %q, want %q", policyErr.Violations, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainsPersonalInfo(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"my-jane.doe@example.com-pw", true},
+		{"JANE.DOEpassword", true},
+		{"turingrocks", true},
+		{"alanpassword", true},
+		{"aXpassword", false},
+		{"unrelated", false},
+	}
+
+	for _, tt := range tests {
+		// "Al" is shorter than 3 characters and ignored, "Alan" is not
+		got := containsPersonalInfo(tt.password, "jane.doe@example.com", "Al Alan-Turing")
+		if got != tt.want {
+			t.Errorf("containsPersonalInfo(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordPolicyBreached(t *testing.T) {
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	dir := t.TempDir()
+	list := "0018A45C4D1DEF81644B54AB7F969B88D65:1\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:3\n"
+	if err := os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte(list), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// SHA-1 of "letmein" is B7A875FC1EA228B9061041B7CEC4BD3C52AB3CE3; a list
+	// without counts only holds breached passwords
+	if err := os.WriteFile(filepath.Join(dir, "b7a87"), []byte("5fc1ea228b9061041b7cec4bd3c52ab3ce3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		minCount int
+		want     bool
+	}{
+		{"password", 1, true},
+		{"password", 3, true},
+		{"password", 4, false},
+		{"letmein", 100, true},
+		{"not in any list", 1, false},
+	}
+
+	for _, tt := range tests {
+		policy := PasswordPolicy{BreachDir: dir, BreachMinCount: tt.minCount}
+		got, err := policy.isBreached(tt.password)
+		if err != nil {
+			t.Fatalf("isBreached(%q): %v", tt.password, err)
+		}
+		if got != tt.want {
+			t.Errorf("isBreached(%q) with min count %d = %v, want %v", tt.password, tt.minCount, got, tt.want)
+		}
+	}
+}
+
+func TestLoadPasswordPolicy(t *testing.T) {
+	t.Setenv("PASSWORD_MIN_LENGTH", "12")
+	t.Setenv("PASSWORD_MAX_LENGTH", "200")
+	t.Setenv("PASSWORD_REQUIRED_CLASSES", " Upper, digit,emoji")
+	t.Setenv("PASSWORD_DISALLOW_PERSONAL_INFO", "false")
+	t.Setenv("PASSWORD_BREACH_DIR", "")
+
+	got := LoadPasswordPolicy()
+	want := PasswordPolicy{
+		MinLength:       12,
+		MaxLength:       bcryptMaxLength,
+		RequiredClasses: []string{"upper", "digit"},
+		BreachMinCount:  1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadPasswordPolicy = %+v, want %+v", got, want)
+	}
+}
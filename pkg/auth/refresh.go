@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"time"
+)
+
+// AccessTokenTTL returns the lifetime of access tokens
+func AccessTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("JWT_EXPIRATION"))
+	if err != nil || ttl <= 0 {
+		return 15 * time.Minute // default to 15 minutes
+	}
+	return ttl
+}
+
+// RefreshTokenTTL returns the lifetime of refresh tokens
+func RefreshTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("JWT_REFRESH_EXPIRATION"))
+	if err != nil || ttl <= 0 {
+		return 30 * 24 * time.Hour // default to 30 days
+	}
+	return ttl
+}
+
+// ImpersonationTokenTTL returns the lifetime of impersonation tokens
+func ImpersonationTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("IMPERSONATION_EXPIRATION"))
+	if err != nil || ttl <= 0 {
+		return 15 * time.Minute // default to 15 minutes
+	}
+	return ttl
+}
+
+// GenerateOpaqueToken returns a random URL-safe token and its SHA-256 hash.
+// Only the hash should ever be stored.
+func GenerateOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex-encoded SHA-256 hash of an opaque token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewTokenID returns a random identifier suitable for token IDs and families
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
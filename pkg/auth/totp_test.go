@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 test key of RFC 6238, "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTP(t *testing.T) {
+	tests := []struct {
+		name        string
+		secret      string
+		code        string
+		at          int64
+		lastCounter int64
+		wantCounter int64
+		wantOK      bool
+	}{
+		// Codes are the last six digits of the RFC 6238 test vectors
+		{"rfc vector", rfc6238Secret, "081804", 1111111109, 0, 37037036, true},
+		{"rfc vector 2", rfc6238Secret, "005924", 1234567890, 0, 41152263, true},
+		{"rfc vector 3", rfc6238Secret, "279037", 2000000000, 0, 66666666, true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "081804", 1111111109, 0, 37037036, true},
+		{"spaces in code", rfc6238Secret, "081 804", 1111111109, 0, 37037036, true},
+		{"previous step", rfc6238Secret, "081804", 1111111109 + totpPeriod, 0, 37037036, true},
+		{"next step", rfc6238Secret, "081804", 1111111109 - totpPeriod, 0, 37037036, true},
+		{"outside skew", rfc6238Secret, "081804", 1111111109 + 2*totpPeriod, 0, 0, false},
+		{"replayed", rfc6238Secret, "081804", 1111111109, 37037036, 0, false},
+		{"wrong code", rfc6238Secret, "123456", 1111111109, 0, 0, false},
+		{"short code", rfc6238Secret, "81804", 1111111109, 0, 0, false},
+		{"invalid secret", "not base32!", "081804", 1111111109, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, ok := ValidateTOTP(tt.secret, tt.code, time.Unix(tt.at, 0), tt.lastCounter)
+			if ok != tt.wantOK || counter != tt.wantCounter {
+				t.Errorf("ValidateTOTP = (%d, %v), want (%d, %v)", counter, ok, tt.wantCounter, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("key is %d bytes, want 20", len(key))
+	}
+
+	code := totpCode(key, uint64(time.Now().Unix()/totpPeriod))
+	if _, ok := ValidateTOTP(secret, code, time.Now(), 0); !ok {
+		t.Errorf("code %s for a new secret was rejected", code)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	got := TOTPURI("Gorecta", "jane@example.com", rfc6238Secret)
+	want := "otpauth://totp/Gorecta:jane@example.com?algorithm=SHA1&digits=6&issuer=Gorecta&period=30&secret=" + rfc6238Secret
+	if got != want {
+		t.Errorf("TOTPURI = %q, want %q", got, want)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes, want 10", len(codes))
+	}
+
+	format := regexp.MustCompile(`^[a-km-np-z2-9]{5}-[a-km-np-z2-9]{5}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q does not match xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q was generated twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"abcde-fghij", "abcdefghij"},
+		{"ABCDE-FGHIJ", "abcdefghij"},
+		{" abcde fghij ", "abcdefghij"},
+		{"abcdefghij", "abcdefghij"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeRecoveryCode(tt.code); got != tt.want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
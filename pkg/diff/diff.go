@@ -0,0 +1,219 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Operation types of a diff
+const (
+	Equal  = "equal"
+	Insert = "insert"
+	Delete = "delete"
+)
+
+// maxEdits bounds the search of very different texts, which are reported as
+// replaced entirely instead. The trace of a search holds about maxEdits²
+// ints, so this keeps a diff under 2MB.
+const maxEdits = 500
+
+// Op is one step of turning the old text into the new one. Consecutive
+// tokens of the same type are merged into a single op.
+type Op struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Lines diffs two texts line by line. Line endings are kept in the op text.
+func Lines(a, b string) []Op {
+	return compute(splitLines(a), splitLines(b))
+}
+
+// Words diffs two texts word by word. Whitespace is kept in the op text so
+// that joining the equal and insert ops reproduces the new text.
+func Words(a, b string) []Op {
+	return compute(splitWords(a), splitWords(b))
+}
+
+func compute(a, b []string) []Op {
+	// Common prefixes and suffixes need no search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Op
+	ops = appendOp(ops, Equal, a[:prefix]...)
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	ops = appendOp(ops, Equal, a[len(a)-suffix:]...)
+	return merge(ops)
+}
+
+// myers finds a shortest edit script with Myers' algorithm. Only the
+// diagonals reachable at each step are kept, so memory grows with the
+// square of the number of differences rather than with the input size.
+func myers(a, b []string) []Op {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return appendOp(appendOp(nil, Delete, a...), Insert, b...)
+	}
+
+	max := n + m
+	if max > maxEdits {
+		max = maxEdits
+	}
+	// trace[d][k+d] is the furthest x reached on diagonal k after d edits
+	var trace [][]int
+	v := []int{0}
+	var found bool
+	for d := 0; d <= max && !found; d++ {
+		next := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && get(v, d-1, k-1) < get(v, d-1, k+1)) {
+				x = get(v, d-1, k+1)
+			} else {
+				x = get(v, d-1, k-1) + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			next[k+d] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		trace = append(trace, next)
+		v = next
+	}
+	if !found {
+		return appendOp(appendOp(nil, Delete, a...), Insert, b...)
+	}
+
+	// Walk the trace backwards to recover the edits
+	var reversed []Op
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && get(trace[d-1], d-1, k-1) < get(trace[d-1], d-1, k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := get(trace[d-1], d-1, prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, Op{Type: Equal, Text: a[x]})
+		}
+		if x == prevX {
+			y--
+			reversed = append(reversed, Op{Type: Insert, Text: b[y]})
+		} else {
+			x--
+			reversed = append(reversed, Op{Type: Delete, Text: a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		reversed = append(reversed, Op{Type: Equal, Text: a[x]})
+	}
+
+	ops := make([]Op, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+// get returns v[k] of the diagonals kept after d edits, where v covers -d..d
+func get(v []int, d, k int) int {
+	if d < 0 || k < -d || k > d {
+		return 0
+	}
+	return v[k+d]
+}
+
+func appendOp(ops []Op, typ string, tokens ...string) []Op {
+	for _, token := range tokens {
+		ops = append(ops, Op{Type: typ, Text: token})
+	}
+	return ops
+}
+
+// merge joins consecutive ops of the same type, listing deletions before
+// insertions within a changed block
+func merge(ops []Op) []Op {
+	var merged []Op
+	for i := 0; i < len(ops); {
+		if ops[i].Type == Equal {
+			j := i
+			var text strings.Builder
+			for ; j < len(ops) && ops[j].Type == Equal; j++ {
+				text.WriteString(ops[j].Text)
+			}
+			merged = append(merged, Op{Type: Equal, Text: text.String()})
+			i = j
+			continue
+		}
+
+		j := i
+		var deleted, inserted strings.Builder
+		for ; j < len(ops) && ops[j].Type != Equal; j++ {
+			if ops[j].Type == Delete {
+				deleted.WriteString(ops[j].Text)
+			} else {
+				inserted.WriteString(ops[j].Text)
+			}
+		}
+		if deleted.Len() > 0 {
+			merged = append(merged, Op{Type: Delete, Text: deleted.String()})
+		}
+		if inserted.Len() > 0 {
+			merged = append(merged, Op{Type: Insert, Text: inserted.String()})
+		}
+		i = j
+	}
+	return merged
+}
+
+// splitLines splits text into lines, keeping their line endings
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// splitWords splits text into words and the whitespace between them
+func splitWords(s string) []string {
+	var tokens []string
+	start, space := 0, false
+	for i, r := range s {
+		isSpace := unicode.IsSpace(r)
+		if i > start && isSpace != space {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+		space = isSpace
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
@@ -0,0 +1,164 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Op
+	}{
+		{
+			name: "identical",
+			a:    "one\ntwo\n",
+			b:    "one\ntwo\n",
+			want: []Op{{Equal, "one\ntwo\n"}},
+		},
+		{
+			name: "both empty",
+			want: nil,
+		},
+		{
+			name: "from empty",
+			b:    "one\n",
+			want: []Op{{Insert, "one\n"}},
+		},
+		{
+			name: "to empty",
+			a:    "one\n",
+			want: []Op{{Delete, "one\n"}},
+		},
+		{
+			name: "changed line",
+			a:    "one\ntwo\nthree\n",
+			b:    "one\n2\nthree\n",
+			want: []Op{{Equal, "one\n"}, {Delete, "two\n"}, {Insert, "2\n"}, {Equal, "three\n"}},
+		},
+		{
+			name: "inserted line",
+			a:    "one\nthree\n",
+			b:    "one\ntwo\nthree\n",
+			want: []Op{{Equal, "one\n"}, {Insert, "two\n"}, {Equal, "three\n"}},
+		},
+		{
+			name: "deleted line",
+			a:    "one\ntwo\nthree\n",
+			b:    "one\nthree\n",
+			want: []Op{{Equal, "one\n"}, {Delete, "two\n"}, {Equal, "three\n"}},
+		},
+		{
+			name: "missing final newline",
+			a:    "one\ntwo",
+			b:    "one\ntwo\n",
+			want: []Op{{Equal, "one\n"}, {Delete, "two"}, {Insert, "two\n"}},
+		},
+		{
+			name: "edits between unchanged lines",
+			a:    "a\nb\nc\nd\ne\n",
+			b:    "a\nc\nd\nx\ne\n",
+			want: []Op{{Equal, "a\n"}, {Delete, "b\n"}, {Equal, "c\nd\n"}, {Insert, "x\n"}, {Equal, "e\n"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			checkOps(t, got, tt.a, tt.b)
+		})
+	}
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Op
+	}{
+		{
+			name: "changed word",
+			a:    "the quick fox",
+			b:    "the slow fox",
+			want: []Op{{Equal, "the "}, {Delete, "quick"}, {Insert, "slow"}, {Equal, " fox"}},
+		},
+		{
+			name: "changed whitespace",
+			a:    "hello world",
+			b:    "hello  world",
+			want: []Op{{Equal, "hello"}, {Delete, " "}, {Insert, "  "}, {Equal, "world"}},
+		},
+		{
+			name: "appended words",
+			a:    "merhaba",
+			b:    "merhaba dünya",
+			want: []Op{{Equal, "merhaba"}, {Insert, " dünya"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Words(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Words(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			checkOps(t, got, tt.a, tt.b)
+		})
+	}
+}
+
+// Texts that differ in more than maxEdits places are replaced entirely,
+// apart from their common prefix and suffix
+func TestLinesBeyondMaxEdits(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < maxEdits; i++ {
+		a.WriteString("old\nsame\n")
+		b.WriteString("new\nsame\n")
+	}
+
+	got := Lines(a.String(), b.String())
+	want := []Op{
+		{Delete, strings.TrimSuffix(a.String(), "same\n")},
+		{Insert, strings.TrimSuffix(b.String(), "same\n")},
+		{Equal, "same\n"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d ops, want the whole text replaced", len(got))
+	}
+}
+
+func TestLinesWithinMaxEdits(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < maxEdits/4; i++ {
+		a.WriteString("old\nsame\n")
+		b.WriteString("new\nsame\n")
+	}
+
+	got := Lines(a.String(), b.String())
+	if len(got) != maxEdits/4*3 {
+		t.Errorf("got %d ops, want %d", len(got), maxEdits/4*3)
+	}
+	checkOps(t, got, a.String(), b.String())
+}
+
+// checkOps verifies that the ops turn a into b
+func checkOps(t *testing.T, ops []Op, a, b string) {
+	t.Helper()
+	var before, after strings.Builder
+	for _, op := range ops {
+		if op.Type != Insert {
+			before.WriteString(op.Text)
+		}
+		if op.Type != Delete {
+			after.WriteString(op.Text)
+		}
+	}
+	if before.String() != a || after.String() != b {
+		t.Errorf("ops rebuild %q -> %q, want %q -> %q", before.String(), after.String(), a, b)
+	}
+}
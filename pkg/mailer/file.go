@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LogMailer writes messages to the application log instead of sending them.
+// It is intended for local development.
+type LogMailer struct{}
+
+// Send logs the message
+func (m *LogMailer) Send(msg Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer writes each message to a separate file in Dir. It is intended
+// for local development and tests that need to read delivered mail.
+type FileMailer struct {
+	Dir string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// Send writes the message to a new file
+func (m *FileMailer) Send(msg Message) error {
+	dir := m.Dir
+	if dir == "" {
+		dir = "./mail"
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %v", err)
+	}
+
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%s-%04d.eml", time.Now().Format("20060102T150405"), m.seq)
+	m.mu.Unlock()
+
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write mail file: %v", err)
+	}
+	return nil
+}
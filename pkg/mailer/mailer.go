@@ -0,0 +1,59 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var defaultMailer Mailer = &LogMailer{}
+
+// InitMailer configures the mailer selected by MAIL_DRIVER (smtp, file or log)
+func InitMailer() (Mailer, error) {
+	var m Mailer
+
+	switch strings.ToLower(os.Getenv("MAIL_DRIVER")) {
+	case "smtp":
+		m = &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+		if os.Getenv("SMTP_HOST") == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required for the smtp mail driver")
+		}
+	case "file":
+		m = &FileMailer{Dir: os.Getenv("MAIL_DIR")}
+	case "", "log":
+		m = &LogMailer{}
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", os.Getenv("MAIL_DRIVER"))
+	}
+
+	defaultMailer = m
+	return m, nil
+}
+
+// GetMailer returns the configured mailer
+func GetMailer() Mailer {
+	return defaultMailer
+}
+
+// SetMailer replaces the configured mailer
+func SetMailer(m Mailer) {
+	defaultMailer = m
+}
@@ -0,0 +1,94 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// idTokenMethods are the signature algorithms accepted for ID tokens.
+// Symmetric algorithms and "none" are never accepted.
+var idTokenMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// Identity is the verified end-user from an ID token
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Claims        map[string]interface{}
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, d *Discovery, raw, nonce string) (*Identity, error) {
+	p.mu.Lock()
+	keys := p.keys
+	p.mu.Unlock()
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keys.key(ctx, p.client, kid)
+	},
+		jwt.WithValidMethods(idTokenMethods),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	if exp, err := claims.GetExpirationTime(); err != nil || exp == nil {
+		return nil, fmt.Errorf("invalid ID token: missing expiry")
+	}
+
+	// With several audiences the token must have been issued to us
+	if aud, _ := claims.GetAudience(); len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.ClientID {
+			return nil, fmt.Errorf("invalid ID token: unexpected authorized party")
+		}
+	}
+
+	tokenNonce, _ := claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("invalid ID token: nonce mismatch")
+	}
+
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		return nil, fmt.Errorf("invalid ID token: missing subject")
+	}
+
+	identity := &Identity{Subject: sub, Claims: claims}
+	identity.fillProfile()
+	return identity, nil
+}
+
+// fillProfile sets the profile fields from the standard claims
+func (i *Identity) fillProfile() {
+	i.Email, _ = i.Claims["email"].(string)
+	i.Email = strings.ToLower(strings.TrimSpace(i.Email))
+
+	// Some providers send email_verified as a string
+	switch v := i.Claims["email_verified"].(type) {
+	case bool:
+		i.EmailVerified = v
+	case string:
+		i.EmailVerified = v == "true"
+	}
+
+	for _, claim := range []string{"name", "preferred_username", "email"} {
+		if name, _ := i.Claims[claim].(string); name != "" {
+			i.Name = name
+			break
+		}
+	}
+}
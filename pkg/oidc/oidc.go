@@ -0,0 +1,355 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// discoveryTTL is how long a provider's discovery document is cached
+const discoveryTTL = time.Hour
+
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+// Config describes an OpenID Connect provider
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// RoleClaim names the ID token claim (a string or a list of strings)
+	// that RoleMapping is matched against
+	RoleClaim   string
+	RoleMapping []RoleMapping
+	DefaultRole string
+	// AllowSignup creates users on their first login
+	AllowSignup bool
+	// SyncRole re-applies RoleMapping on every login
+	SyncRole bool
+}
+
+// RoleMapping maps a claim value to a role
+type RoleMapping struct {
+	Value string
+	Role  string
+}
+
+// Discovery is the subset of the provider's discovery document that is used
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider performs the authorization code flow against one identity provider
+type Provider struct {
+	Config
+	client *http.Client
+
+	mu           sync.Mutex
+	discovery    *Discovery
+	discoveredAt time.Time
+	keys         *keyCache
+}
+
+// NewProvider returns a provider that makes its requests with client, or a
+// default client if client is nil
+func NewProvider(cfg Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{Config: cfg, client: client}
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   []*Provider
+)
+
+// LoadProviders configures the providers listed in OIDC_PROVIDERS. Each
+// provider is read from OIDC_<NAME>_* variables, e.g. OIDC_COMPANY_ISSUER.
+func LoadProviders() error {
+	var loaded []*Provider
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name == "" {
+			continue
+		}
+
+		cfg, err := configFromEnv(name)
+		if err != nil {
+			return err
+		}
+		loaded = append(loaded, NewProvider(cfg, nil))
+	}
+
+	SetProviders(loaded...)
+	return nil
+}
+
+// SetProviders replaces the configured providers
+func SetProviders(p ...*Provider) {
+	providersMu.Lock()
+	providers = p
+	providersMu.Unlock()
+}
+
+// Providers returns the configured providers
+func Providers() []*Provider {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return providers
+}
+
+// GetProvider returns the configured provider with the name
+func GetProvider(name string) (*Provider, error) {
+	for _, p := range Providers() {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, ErrUnknownProvider
+}
+
+func configFromEnv(name string) (Config, error) {
+	prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+	env := func(key string) string {
+		return strings.TrimSpace(os.Getenv(prefix + key))
+	}
+
+	cfg := Config{
+		Name:         name,
+		Issuer:       env("ISSUER"),
+		ClientID:     env("CLIENT_ID"),
+		ClientSecret: env("CLIENT_SECRET"),
+		RedirectURL:  env("REDIRECT_URL"),
+		RoleClaim:    env("ROLE_CLAIM"),
+		DefaultRole:  env("DEFAULT_ROLE"),
+		AllowSignup:  env("ALLOW_SIGNUP") != "false",
+		SyncRole:     env("SYNC_ROLE") == "true",
+	}
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		return Config{}, fmt.Errorf("%sISSUER and %sCLIENT_ID are required", prefix, prefix)
+	}
+
+	if cfg.RedirectURL == "" {
+		cfg.RedirectURL = strings.TrimRight(os.Getenv("APP_URL"), "/") + "/auth/oidc/" + name + "/callback"
+	}
+	if cfg.DefaultRole == "" {
+		cfg.DefaultRole = "user"
+	}
+
+	cfg.Scopes = strings.Fields(strings.ReplaceAll(env("SCOPES"), ",", " "))
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	// ROLE_MAPPING is a comma-separated list of claim_value=role pairs
+	for _, pair := range strings.Split(env("ROLE_MAPPING"), ",") {
+		value, role, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		cfg.RoleMapping = append(cfg.RoleMapping, RoleMapping{
+			Value: strings.TrimSpace(value),
+			Role:  strings.TrimSpace(role),
+		})
+	}
+
+	return cfg, nil
+}
+
+// Discover returns the provider's discovery document, fetching it from
+// <issuer>/.well-known/openid-configuration when it is not cached
+func (p *Provider) Discover(ctx context.Context) (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil && time.Since(p.discoveredAt) < discoveryTTL {
+		return p.discovery, nil
+	}
+
+	var d Discovery
+	wellKnown := strings.TrimRight(p.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, "", &d); err != nil {
+		return nil, fmt.Errorf("failed to fetch discovery document: %v", err)
+	}
+
+	if strings.TrimRight(d.Issuer, "/") != strings.TrimRight(p.Issuer, "/") {
+		return nil, fmt.Errorf("discovery document issuer %q does not match %q", d.Issuer, p.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document is missing required endpoints")
+	}
+
+	if p.keys == nil || p.keys.uri != d.JWKSURI {
+		p.keys = newKeyCache(d.JWKSURI)
+	}
+	p.discovery = &d
+	p.discoveredAt = time.Now()
+	return p.discovery, nil
+}
+
+// AuthCodeURL returns the URL the user is sent to in order to log in. The
+// code challenge is the S256 PKCE challenge of the verifier later passed to
+// Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %v", err)
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified identity
+// from the ID token
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {codeVerifier},
+		"client_id":     {p.ClientID},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("invalid token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("token request failed: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+
+	identity, err := p.verifyIDToken(ctx, d, token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some providers only return profile claims from the userinfo endpoint
+	if identity.Email == "" && d.UserinfoEndpoint != "" && token.AccessToken != "" {
+		var info map[string]interface{}
+		if err := p.getJSON(ctx, d.UserinfoEndpoint, token.AccessToken, &info); err != nil {
+			return nil, fmt.Errorf("failed to fetch userinfo: %v", err)
+		}
+		if sub, _ := info["sub"].(string); sub != identity.Subject {
+			return nil, fmt.Errorf("userinfo subject does not match the ID token")
+		}
+		for k, v := range info {
+			if _, ok := identity.Claims[k]; !ok {
+				identity.Claims[k] = v
+			}
+		}
+		identity.fillProfile()
+	}
+
+	return identity, nil
+}
+
+// RoleFor returns the role mapped from the identity's role claim, or an
+// empty string if no mapping matches
+func (p *Provider) RoleFor(identity *Identity) string {
+	if p.RoleClaim == "" {
+		return ""
+	}
+
+	var values []string
+	switch v := identity.Claims[p.RoleClaim].(type) {
+	case string:
+		values = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	// The first mapping in configuration order wins
+	for _, m := range p.RoleMapping {
+		for _, v := range values {
+			if v == m.Value {
+				return m.Role
+			}
+		}
+	}
+	return ""
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint, bearer string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s from %s", resp.Status, endpoint)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,451 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "gorecta"
+	testKeyID    = "test-key"
+	testNonce    = "test-nonce"
+)
+
+// mockProvider is an identity provider serving discovery, JWKS, token and
+// userinfo endpoints. The token endpoint issues an ID token with the claims
+// returned by idToken.
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu       sync.Mutex
+	idToken  func(claims jwt.MapClaims) string
+	claims   jwt.MapClaims
+	userinfo map[string]interface{}
+	form     url.Values
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	m := &mockProvider{key: key}
+	m.idToken = m.sign
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, Discovery{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			UserinfoEndpoint:      m.server.URL + "/userinfo",
+			JWKSURI:               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKeyID,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		m.mu.Lock()
+		m.form = r.PostForm
+		idToken := m.idToken(m.claims)
+		m.mu.Unlock()
+
+		writeJSON(w, map[string]string{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		writeJSON(w, m.userinfo)
+	})
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// provider returns a provider configured against the mock
+func (m *mockProvider) provider(cfg Config) *Provider {
+	cfg.Name = "mock"
+	cfg.Issuer = m.server.URL
+	cfg.ClientID = testClientID
+	cfg.RedirectURL = "https://cms.example.com/auth/oidc/mock/callback"
+	return NewProvider(cfg, m.server.Client())
+}
+
+// validClaims returns the claims of an ID token the provider accepts.
+// overrides replaces claims; a nil value removes the claim.
+func (m *mockProvider) validClaims(overrides jwt.MapClaims) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            m.server.URL,
+		"sub":            "user-1",
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          testNonce,
+		"email":          "Jane@Example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+func (m *mockProvider) sign(claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func (m *mockProvider) setClaims(claims jwt.MapClaims) {
+	m.mu.Lock()
+	m.claims = claims
+	m.mu.Unlock()
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestAuthCodeURLSendsPKCEChallenge(t *testing.T) {
+	m := newMockProvider(t)
+	p := m.provider(Config{Scopes: []string{"openid", "email"}})
+
+	verifier, err := GenerateCodeVerifier()
+	if err != nil {
+		t.Fatalf("GenerateCodeVerifier: %v", err)
+	}
+
+	raw, err := p.AuthCodeURL(context.Background(), "state-1", testNonce, CodeChallenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("invalid URL %q: %v", raw, err)
+	}
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          p.RedirectURL,
+		"scope":                 "openid email",
+		"state":                 "state-1",
+		"nonce":                 testNonce,
+		"code_challenge":        CodeChallenge(verifier),
+		"code_challenge_method": "S256",
+	}
+	for k, v := range want {
+		if got := u.Query().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if !strings.HasPrefix(raw, m.server.URL+"/authorize?") {
+		t.Errorf("URL %q does not use the authorization endpoint", raw)
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// Example from RFC 7636, appendix B
+	got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("CodeChallenge = %q, want %q", got, want)
+	}
+}
+
+func TestExchangeForwardsCodeVerifier(t *testing.T) {
+	m := newMockProvider(t)
+	m.setClaims(m.validClaims(nil))
+	p := m.provider(Config{})
+
+	identity, err := p.Exchange(context.Background(), "code-1", "verifier-1", testNonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	m.mu.Lock()
+	form := m.form
+	m.mu.Unlock()
+	want := map[string]string{
+		"grant_type":    "authorization_code",
+		"code":          "code-1",
+		"code_verifier": "verifier-1",
+		"redirect_uri":  p.RedirectURL,
+		"client_id":     testClientID,
+	}
+	for k, v := range want {
+		if got := form.Get(k); got != v {
+			t.Errorf("token request %s = %q, want %q", k, got, v)
+		}
+	}
+
+	if identity.Subject != "user-1" {
+		t.Errorf("Subject = %q, want %q", identity.Subject, "user-1")
+	}
+	if identity.Email != "jane@example.com" {
+		t.Errorf("Email = %q, want %q", identity.Email, "jane@example.com")
+	}
+	if identity.Name != "Jane Doe" {
+		t.Errorf("Name = %q, want %q", identity.Name, "Jane Doe")
+	}
+}
+
+func TestExchangeRejectsInvalidIDTokens(t *testing.T) {
+	m := newMockProvider(t)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		nonce  string
+	}{
+		{"nonce mismatch", nil, "other-nonce"},
+		{"missing nonce", jwt.MapClaims{"nonce": nil}, testNonce},
+		{"wrong issuer", jwt.MapClaims{"iss": "https://evil.example.com"}, testNonce},
+		{"wrong audience", jwt.MapClaims{"aud": "other-client"}, testNonce},
+		{"several audiences without azp", jwt.MapClaims{"aud": []string{testClientID, "other-client"}}, testNonce},
+		{"several audiences for another party", jwt.MapClaims{"aud": []string{testClientID, "other-client"}, "azp": "other-client"}, testNonce},
+		{"expired", jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}, testNonce},
+		{"missing expiry", jwt.MapClaims{"exp": nil}, testNonce},
+		{"missing subject", jwt.MapClaims{"sub": nil}, testNonce},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m.setClaims(m.validClaims(tt.claims))
+			if _, err := m.provider(Config{}).Exchange(context.Background(), "code", "verifier", tt.nonce); err == nil {
+				t.Fatal("Exchange accepted an invalid ID token")
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsUntrustedSignatures(t *testing.T) {
+	m := newMockProvider(t)
+	m.setClaims(m.validClaims(nil))
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		sign func(claims jwt.MapClaims) (string, error)
+	}{
+		{"unknown key", func(claims jwt.MapClaims) (string, error) {
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+			token.Header["kid"] = testKeyID
+			return token.SignedString(other)
+		}},
+		{"symmetric algorithm", func(claims jwt.MapClaims) (string, error) {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			token.Header["kid"] = testKeyID
+			return token.SignedString([]byte(testClientID))
+		}},
+		{"none algorithm", func(claims jwt.MapClaims) (string, error) {
+			return jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m.mu.Lock()
+			m.idToken = func(claims jwt.MapClaims) string {
+				signed, err := tt.sign(claims)
+				if err != nil {
+					panic(err)
+				}
+				return signed
+			}
+			m.mu.Unlock()
+
+			if _, err := m.provider(Config{}).Exchange(context.Background(), "code", "verifier", testNonce); err == nil {
+				t.Fatal("Exchange accepted an ID token with an untrusted signature")
+			}
+		})
+	}
+}
+
+func TestDiscoverRejectsIssuerMismatch(t *testing.T) {
+	m := newMockProvider(t)
+	p := m.provider(Config{})
+	p.Issuer = m.server.URL + "/"
+	if _, err := p.Discover(context.Background()); err != nil {
+		t.Fatalf("Discover rejected an issuer differing by a trailing slash: %v", err)
+	}
+
+	// The discovery document is served by the mock but claims its own URL
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, Discovery{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JWKSURI:               m.server.URL + "/jwks",
+		})
+	})
+	impostor := httptest.NewServer(mux)
+	defer impostor.Close()
+
+	p = NewProvider(Config{Name: "impostor", Issuer: impostor.URL, ClientID: testClientID}, impostor.Client())
+	if _, err := p.Discover(context.Background()); err == nil {
+		t.Fatal("Discover accepted a document for another issuer")
+	}
+}
+
+// Accounts are only linked by email when the provider reports the email as
+// verified, so the claim must be read strictly
+func TestExchangeEmailVerification(t *testing.T) {
+	m := newMockProvider(t)
+
+	tests := []struct {
+		name     string
+		verified interface{}
+		want     bool
+	}{
+		{"verified", true, true},
+		{"verified as string", "true", true},
+		{"unverified", false, false},
+		{"unverified as string", "false", false},
+		{"unexpected value", 1, false},
+		{"missing", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m.setClaims(m.validClaims(jwt.MapClaims{"email_verified": tt.verified}))
+			identity, err := m.provider(Config{}).Exchange(context.Background(), "code", "verifier", testNonce)
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			if identity.EmailVerified != tt.want {
+				t.Errorf("EmailVerified = %v, want %v", identity.EmailVerified, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeFetchesUserinfo(t *testing.T) {
+	m := newMockProvider(t)
+	m.setClaims(m.validClaims(jwt.MapClaims{"email": nil, "email_verified": nil, "name": nil}))
+
+	m.mu.Lock()
+	m.userinfo = map[string]interface{}{"sub": "user-1", "email": "jane@example.com", "email_verified": true}
+	m.mu.Unlock()
+	identity, err := m.provider(Config{}).Exchange(context.Background(), "code", "verifier", testNonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Email != "jane@example.com" || !identity.EmailVerified {
+		t.Errorf("identity = %+v, want the verified email from userinfo", identity)
+	}
+
+	// Userinfo for another subject must not fill in the identity
+	m.mu.Lock()
+	m.userinfo = map[string]interface{}{"sub": "user-2", "email": "john@example.com", "email_verified": true}
+	m.mu.Unlock()
+	if _, err := m.provider(Config{}).Exchange(context.Background(), "code", "verifier", testNonce); err == nil {
+		t.Fatal("Exchange accepted userinfo for another subject")
+	}
+}
+
+func TestRoleFor(t *testing.T) {
+	p := NewProvider(Config{
+		RoleClaim: "groups",
+		RoleMapping: []RoleMapping{
+			{Value: "cms-admins", Role: "admin"},
+			{Value: "cms-editors", Role: "editor"},
+		},
+	}, nil)
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		want   string
+	}{
+		{"string claim", map[string]interface{}{"groups": "cms-editors"}, "editor"},
+		{"list claim", map[string]interface{}{"groups": []interface{}{"staff", "cms-editors"}}, "editor"},
+		{"first mapping wins", map[string]interface{}{"groups": []interface{}{"cms-editors", "cms-admins"}}, "admin"},
+		{"no match", map[string]interface{}{"groups": []interface{}{"staff"}}, ""},
+		{"missing claim", map[string]interface{}{}, ""},
+		{"unexpected type", map[string]interface{}{"groups": 7}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.RoleFor(&Identity{Claims: tt.claims}); got != tt.want {
+				t.Errorf("RoleFor = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	p.RoleClaim = ""
+	if got := p.RoleFor(&Identity{Claims: map[string]interface{}{"groups": "cms-admins"}}); got != "" {
+		t.Errorf("RoleFor without a role claim = %q, want none", got)
+	}
+}
+
+func TestExchangeMapsRoleClaim(t *testing.T) {
+	m := newMockProvider(t)
+	m.setClaims(m.validClaims(jwt.MapClaims{"roles": []string{"staff", "cms-editors"}}))
+	p := m.provider(Config{
+		RoleClaim:   "roles",
+		RoleMapping: []RoleMapping{{Value: "cms-editors", Role: "editor"}},
+	})
+
+	identity, err := p.Exchange(context.Background(), "code", "verifier", testNonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if got := p.RoleFor(identity); got != "editor" {
+		t.Errorf("RoleFor = %q, want %q", got, "editor")
+	}
+}
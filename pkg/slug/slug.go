@@ -0,0 +1,85 @@
+package slug
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxLength is the length slugs are cut to, leaving room in the column for
+// a numeric suffix
+const MaxLength = 80
+
+// transliterations spells letters outside ASCII with ASCII letters. Turkish
+// letters come first; the rest covers the Latin letters of other European
+// languages.
+var transliterations = map[rune]string{
+	'ç': "c", 'Ç': "c", 'ğ': "g", 'Ğ': "g", 'ı': "i", 'İ': "i",
+	'ö': "o", 'Ö': "o", 'ş': "s", 'Ş': "s", 'ü': "u", 'Ü': "u",
+	'â': "a", 'Â': "a", 'î': "i", 'Î': "i", 'û': "u", 'Û': "u",
+
+	'à': "a", 'á': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'À': "a", 'Á': "a", 'Ã': "a", 'Ä': "a", 'Å': "a", 'Ā': "a", 'Ă': "a", 'Ą': "a",
+	'æ': "ae", 'Æ': "ae", 'ć': "c", 'Ć': "c", 'č': "c", 'Č': "c", 'ď': "d", 'Ď': "d",
+	'đ': "d", 'Đ': "d", 'ð': "d", 'Ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'È': "e", 'É': "e", 'Ê': "e", 'Ë': "e", 'Ē': "e", 'Ė': "e", 'Ę': "e", 'Ě': "e",
+	'ì': "i", 'í': "i", 'ï': "i", 'ī': "i", 'į': "i", 'Ì': "i", 'Í': "i", 'Ï': "i", 'Ī': "i", 'Į': "i",
+	'ł': "l", 'Ł': "l", 'ľ': "l", 'Ľ': "l", 'ñ': "n", 'Ñ': "n", 'ń': "n", 'Ń': "n", 'ň': "n", 'Ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ø': "o", 'ō': "o", 'ő': "o",
+	'Ò': "o", 'Ó': "o", 'Ô': "o", 'Õ': "o", 'Ø': "o", 'Ō': "o", 'Ő': "o",
+	'œ': "oe", 'Œ': "oe", 'ř': "r", 'Ř': "r", 'ś': "s", 'Ś': "s", 'š': "s", 'Š': "s", 'ß': "ss",
+	'ť': "t", 'Ť': "t", 'þ': "th", 'Þ': "th",
+	'ù': "u", 'ú': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'Ù': "u", 'Ú': "u", 'Ū': "u", 'Ů': "u", 'Ű': "u", 'Ų': "u",
+	'ý': "y", 'ÿ': "y", 'Ý': "y", 'ź': "z", 'Ź': "z", 'ż': "z", 'Ż': "z", 'ž': "z", 'Ž': "z",
+}
+
+// Make turns text into a URL slug: ASCII lowercase letters and digits
+// separated by single hyphens, e.g. "Güneşli Günler İçin" becomes
+// "gunesli-gunler-icin". Letters that cannot be transliterated are dropped,
+// so the result may be empty.
+func Make(text string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range text {
+		var s string
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			s = string(unicode.ToLower(r))
+		case transliterations[r] != "":
+			s = transliterations[r]
+		case r == '\'' || r == '’':
+			// Apostrophes join suffixes to words, as in "Türkiye'nin"
+			continue
+		case unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hyphen = b.Len() > 0
+			continue
+		default:
+			continue
+		}
+
+		if hyphen {
+			b.WriteByte('-')
+			hyphen = false
+		}
+		b.WriteString(s)
+	}
+
+	return truncate(b.String())
+}
+
+// truncate cuts a slug to MaxLength, at a hyphen when there is one
+func truncate(s string) string {
+	if len(s) <= MaxLength {
+		return s
+	}
+	if s[MaxLength] == '-' {
+		return s[:MaxLength]
+	}
+	s = s[:MaxLength]
+	if i := strings.LastIndexByte(s, '-'); i > 0 {
+		s = s[:i]
+	}
+	return strings.TrimSuffix(s, "-")
+}
@@ -0,0 +1,86 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Hello World", "hello-world"},
+		{"Güneşli Günler İçin", "gunesli-gunler-icin"},
+		{"ÇAĞDAŞ IŞIK ÖĞÜT", "cagdas-isik-ogut"},
+		{"Türkiye'nin Başkenti", "turkiyenin-baskenti"},
+		{"Türkiye’nin Başkenti", "turkiyenin-baskenti"},
+		{"Kâğıt ve Hâlâ", "kagit-ve-hala"},
+		{"Crème brûlée à la française", "creme-brulee-a-la-francaise"},
+		{"Straße & Łódź", "strasse-lodz"},
+		{"  --Go 1.21: What's new?--  ", "go-1-21-whats-new"},
+		{"C++ vs. C#", "c-vs-c"},
+		{"100% Pure", "100-pure"},
+		{"日本語", ""},
+		{"Emoji 🎉 party", "emoji-party"},
+		{"", ""},
+		{"!!!", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Make(tt.text); got != tt.want {
+			t.Errorf("Make(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMakeMaxLength(t *testing.T) {
+	word := strings.Repeat("a", 9) // nine letters and a hyphen fill ten bytes
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "short enough",
+			text: strings.Repeat("a", MaxLength),
+			want: strings.Repeat("a", MaxLength),
+		},
+		{
+			name: "cut at the last hyphen",
+			text: strings.Repeat(word+" ", 8) + "bbbbb",
+			want: strings.TrimSuffix(strings.Repeat(word+"-", 8), "-"),
+		},
+		{
+			name: "cut exactly before a hyphen",
+			text: strings.Repeat(word+" ", 7) + word + "b more",
+			want: strings.Repeat(word+"-", 7) + word + "b",
+		},
+		{
+			name: "one long word is cut inside it",
+			text: strings.Repeat("a", MaxLength+20),
+			want: strings.Repeat("a", MaxLength),
+		},
+		{
+			name: "transliterated letters count in the ASCII result",
+			text: strings.Repeat("ş", MaxLength+1),
+			want: strings.Repeat("s", MaxLength),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Make(tt.text)
+			if got != tt.want {
+				t.Errorf("Make = %q, want %q", got, tt.want)
+			}
+			if len(got) > MaxLength {
+				t.Errorf("slug is %d bytes, want at most %d", len(got), MaxLength)
+			}
+			if strings.HasSuffix(got, "-") {
+				t.Errorf("slug %q ends with a hyphen", got)
+			}
+		})
+	}
+}